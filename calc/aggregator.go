@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"hash"
+	"hash/fnv"
+
+	"github.com/nixpare/sorting"
+)
+
+// Aggregator adds up measurements as they come, a line or a value at a
+// time, instead of reading them from a file. It keeps the stations like
+// the workers of process, keyed by the FNV-1a hash of their name, and
+// its results are rounded the same way.
+// An Aggregator is not safe for concurrent use: every goroutine should
+// own one, and merge it into another one when done
+type Aggregator struct {
+	h hash.Hash64
+	m map[uint64]*WeatherStationInfo
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		h: fnv.New64a(),
+		m: make(map[uint64]*WeatherStationInfo),
+	}
+}
+
+// AddLine adds a "<name>;<temperature>" line, with or without its "\n"
+// or "\r\n". Like the batch path, it does not validate the line
+func (a *Aggregator) AddLine(line []byte) {
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+	parseLine(line, true, a.h, a.m)
+}
+
+// Add adds a measurement of the station with the given name, in tenths
+// of a degree
+func (a *Aggregator) Add(name string, tenths int16) {
+	a.h.Reset()
+	a.h.Write([]byte(name))
+	nameHash := a.h.Sum64()
+
+	wsi, ok := a.m[nameHash]
+	if !ok {
+		a.m[nameHash] = &WeatherStationInfo{
+			name: name,
+			min:  tenths, max: tenths,
+			acc: int64(tenths), count: 1,
+		}
+	} else {
+		wsi.add(tenths)
+	}
+}
+
+// Merge adds the measurements of other, which is left unchanged. It
+// costs one lookup per station of other, whatever the number of values
+func (a *Aggregator) Merge(other *Aggregator) {
+	for nameHash, y := range other.m {
+		x, ok := a.m[nameHash]
+		if !ok {
+			wsi := *y
+			a.m[nameHash] = &wsi
+		} else {
+			x.merge(y)
+		}
+	}
+}
+
+// Reset forgets every measurement, keeping the memory of the table
+func (a *Aggregator) Reset() {
+	clear(a.m)
+}
+
+// Len returns the number of stations seen so far
+func (a *Aggregator) Len() int {
+	return len(a.m)
+}
+
+// Results returns a snapshot of the stations sorted by name, as expected
+// by printResult. It does not change with the next measurements added
+func (a *Aggregator) Results() []*WeatherStationInfo {
+	result := make([]*WeatherStationInfo, 0, len(a.m))
+	for _, wsi := range a.m {
+		snapshot := *wsi
+		result = append(result, &snapshot)
+	}
+
+	sorting.Sort(result)
+	return result
+}
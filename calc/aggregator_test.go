@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAggregator(t *testing.T) {
+	data := fuzzMeasurements(bytes.Repeat([]byte{7, 200, 13, 0, 255, 1, 42}, 300))
+	var want bytes.Buffer
+	printResult(&want, referenceResult(data))
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+
+	t.Run("lines", func(t *testing.T) {
+		a := NewAggregator()
+		for _, line := range lines {
+			a.AddLine(line)
+		}
+
+		var got bytes.Buffer
+		printResult(&got, a.Results())
+		if diff := diffResults(got.String(), want.String()); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("values", func(t *testing.T) {
+		a := NewAggregator()
+		for _, line := range lines {
+			if len(line) == 0 {
+				continue
+			}
+			name, temp, _ := strings.Cut(strings.TrimSpace(string(line)), ";")
+			tenths, err := strconv.Atoi(strings.Replace(temp, ".", "", 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.Add(name, int16(tenths))
+		}
+
+		var got bytes.Buffer
+		printResult(&got, a.Results())
+		if diff := diffResults(got.String(), want.String()); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		// one aggregator per goroutine, merged at the end
+		parts := make([]*Aggregator, 5)
+		for i := range parts {
+			parts[i] = NewAggregator()
+		}
+		for i, line := range lines {
+			parts[i%len(parts)].AddLine(bytes.ReplaceAll(line, []byte("\n"), []byte("\r\n")))
+		}
+
+		a := NewAggregator()
+		for _, part := range parts {
+			a.Merge(part)
+		}
+		before := parts[0].Results()
+		a.Merge(NewAggregator())
+
+		var got bytes.Buffer
+		printResult(&got, a.Results())
+		if diff := diffResults(got.String(), want.String()); diff != "" {
+			t.Error(diff)
+		}
+
+		// the merged aggregators are left alone
+		a.AddLine([]byte("a;99.9"))
+		var after, beforeOut bytes.Buffer
+		printResult(&after, parts[0].Results())
+		printResult(&beforeOut, before)
+		if after.String() != beforeOut.String() {
+			t.Error("merging changed the merged aggregator")
+		}
+	})
+}
+
+func TestAggregatorResultsAreSnapshots(t *testing.T) {
+	a := NewAggregator()
+	a.AddLine([]byte("Hamburg;12.0\n"))
+	a.Add("Bulawayo", 89)
+	snapshot := a.Results()
+
+	a.AddLine([]byte("Hamburg;-3.4"))
+	if a.Len() != 2 {
+		t.Errorf("got %d stations, want 2", a.Len())
+	}
+
+	var got bytes.Buffer
+	printResult(&got, snapshot)
+	want := "{\n\tBulawayo=8.9/8.9/8.9,\n\tHamburg=12.0/12.0/12.0\n}\n"
+	if diff := diffResults(got.String(), want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func BenchmarkAggregatorAddLine(b *testing.B) {
+	data := fuzzMeasurements(bytes.Repeat([]byte{7, 200, 13, 0, 255, 1, 42}, 3000))
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	a := NewAggregator()
+
+	b.SetBytes(int64(len(data)))
+	for range b.N {
+		for _, line := range lines {
+			a.AddLine(line)
+		}
+	}
+}
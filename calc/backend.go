@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// URING_DEPTH is the default number of reads a worker of the io_uring
+// backend keeps in flight
+const URING_DEPTH = 8
+
+// chunkReader gives the bytes of a chunk of a file in order, a buffer at
+// a time
+type chunkReader interface {
+	// next returns the following bytes of the chunk, which are only valid
+	// until the next call, or io.EOF after the last ones
+	next() ([]byte, error)
+	close() error
+}
+
+// backend is how the workers of process read their chunk of a file
+type backend interface {
+	// open returns a reader of the bytes of f between from and to, in
+	// buffers of at most bufferSize bytes
+	open(f *os.File, from int64, to int64, bufferSize int) (chunkReader, error)
+	// workers is the number of workers when process is not given one
+	workers(fileSize int64) int
+	String() string
+}
+
+// parseBackend returns the backend with the given name: pread, mmap or
+// uring, which keeps depth reads in flight
+func parseBackend(name string, depth int) (backend, error) {
+	switch name {
+	case "pread":
+		return preadBackend{}, nil
+	case "mmap":
+		return mmapBackend{}, nil
+	case "uring":
+		if depth < 1 {
+			return nil, errors.New("the queue depth must be at least 1")
+		}
+		return uringBackend{depth: depth}, nil
+	default:
+		return nil, fmt.Errorf("unknown read backend %q: expected pread, mmap or uring", name)
+	}
+}
+
+// blockingWorkers is the number of workers of the backends whose reads
+// block a thread: enough of them to keep the disk busy while the others
+// parse
+func blockingWorkers(fileSize int64) int {
+	if fileSize < BUFFER_SIZE {
+		return 1
+	}
+	return runtime.NumCPU() * WORKERS_MULTIPLIER
+}
+
+// preadBackend reads every buffer with a positional read, which is what
+// os.File.ReadAt does. It is the default backend
+type preadBackend struct{}
+
+func (preadBackend) open(f *os.File, from int64, to int64, bufferSize int) (chunkReader, error) {
+	return &preadReader{f: f, pos: from, to: to, buf: make([]byte, bufferSize)}, nil
+}
+
+func (preadBackend) workers(fileSize int64) int { return blockingWorkers(fileSize) }
+func (preadBackend) String() string             { return "pread" }
+
+type preadReader struct {
+	f       *os.File
+	pos, to int64
+	buf     []byte
+}
+
+func (r *preadReader) next() ([]byte, error) {
+	if r.pos >= r.to {
+		return nil, io.EOF
+	}
+	size := min(int64(len(r.buf)), r.to-r.pos)
+
+	n, err := r.f.ReadAt(r.buf[:size], r.pos)
+	if err == io.EOF && int64(n) < size {
+		// the file is shorter than when it was split in chunks
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading %d bytes at %d: %w", r.f.Name(), size, r.pos, err)
+	}
+	r.pos += int64(n)
+	return r.buf[:n], nil
+}
+
+func (r *preadReader) close() error { return nil }
+
+// mmapBackend maps the chunk of every worker in memory, and gives it in
+// slices of the mapping without copying them. A file truncated while it
+// is mapped kills the process with SIGBUS instead of failing a read
+type mmapBackend struct{}
+
+func (mmapBackend) open(f *os.File, from int64, to int64, bufferSize int) (chunkReader, error) {
+	if from == to {
+		return preadBackend{}.open(f, from, to, bufferSize)
+	}
+	return newMmapReader(f, from, to, bufferSize)
+}
+
+func (mmapBackend) workers(fileSize int64) int { return blockingWorkers(fileSize) }
+func (mmapBackend) String() string             { return "mmap" }
+
+// uringBackend submits the reads of every worker to its own io_uring,
+// keeping depth of them in flight in a ring of registered buffers: the
+// worker parses a buffer while the kernel fills the next ones, so there
+// is no need for more workers than CPUs. Where io_uring is not available,
+// it reads with pread instead
+type uringBackend struct {
+	depth int
+}
+
+// errNoURing is returned by newURingReader when io_uring is not supported
+// by the platform or the kernel, or is disabled
+var errNoURing = errors.New("io_uring is not available")
+
+var uringFallback sync.Once
+
+func (b uringBackend) open(f *os.File, from int64, to int64, bufferSize int) (chunkReader, error) {
+	if from == to {
+		return preadBackend{}.open(f, from, to, bufferSize)
+	}
+
+	r, err := newURingReader(f, from, to, bufferSize, b.depth)
+	if errors.Is(err, errNoURing) {
+		uringFallback.Do(func() {
+			log.Printf("%v, reading with pread instead\n", err)
+		})
+		return preadBackend{}.open(f, from, to, bufferSize)
+	}
+	return r, err
+}
+
+func (b uringBackend) workers(fileSize int64) int {
+	if fileSize < BUFFER_SIZE {
+		return 1
+	}
+	return runtime.NumCPU()
+}
+
+func (b uringBackend) String() string { return "uring/depth=" + strconv.Itoa(b.depth) }
@@ -0,0 +1,166 @@
+//go:build linux && (amd64 || arm64 || riscv64 || loong64)
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestURingLayout(t *testing.T) {
+	// the sizes of the structures shared with the kernel
+	if s := unsafe.Sizeof(uringParams{}); s != 120 {
+		t.Errorf("io_uring_params is %d bytes, expected 120", s)
+	}
+	if s := unsafe.Sizeof(uringSQE{}); s != 64 {
+		t.Errorf("io_uring_sqe is %d bytes, expected 64", s)
+	}
+	if s := unsafe.Sizeof(uringCQE{}); s != 16 {
+		t.Errorf("io_uring_cqe is %d bytes, expected 16", s)
+	}
+}
+
+// TestURingAvailable checks that the uring backend does not fall back to
+// pread where io_uring can be used, which would make its other tests
+// test pread instead
+func TestURingAvailable(t *testing.T) {
+	f, err := os.Open(filepath.Join(goldenDir, "unique-10000.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := newURingReader(f, 0, 100, 16, URING_DEPTH)
+	if errors.Is(err, errNoURing) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	r.close()
+
+	r, err = uringBackend{depth: URING_DEPTH}.open(f, 0, 100, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.close()
+	if _, ok := r.(*uringReader); !ok {
+		t.Errorf("got a %T", r)
+	}
+}
+
+// TestURingUnregistered reads with IORING_OP_READV, as when the buffers
+// cannot be registered
+func TestURingUnregistered(t *testing.T) {
+	path := filepath.Join(goldenDir, "unique-10000.txt")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, bufferSize := range []int{7, 4096} {
+		from, to := int64(1000), int64(len(data)-1000)
+		r, err := openURingReader(f, from, to, bufferSize, 3, false)
+		if errors.Is(err, errNoURing) {
+			t.Skip(err)
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if r.fixed {
+			t.Fatal("the buffers are registered")
+		}
+
+		var got []byte
+		for {
+			buf, err := r.next()
+			if err != nil {
+				if err != io.EOF {
+					t.Fatal(err)
+				}
+				break
+			}
+			got = append(got, buf...)
+		}
+		r.close()
+		if !bytes.Equal(got, data[from:to]) {
+			t.Errorf("buffer %d: got %d different bytes", bufferSize, len(got))
+		}
+	}
+}
+
+// BenchmarkBackends compares the backends on a file of about 64 MB, in the
+// page cache or not. Dropping it needs the file to be clean, which it is
+// once it has been synced
+func BenchmarkBackends(b *testing.B) {
+	unique, err := os.ReadFile(filepath.Join(goldenDir, "unique-10000.txt"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	path := filepath.Join(b.TempDir(), "measurements.txt")
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, err = f.Write(bytes.Repeat(unique, 64<<20/len(unique)))
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		b.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	dropCache := func() {
+		f, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer f.Close()
+		const POSIX_FADV_DONTNEED = 4
+		_, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, f.Fd(), 0, 0, POSIX_FADV_DONTNEED, 0, 0)
+		if errno != 0 {
+			b.Fatal(errno)
+		}
+	}
+
+	for _, be := range []backend{preadBackend{}, mmapBackend{}, uringBackend{depth: URING_DEPTH}} {
+		for _, cold := range []bool{false, true} {
+			cache := "warm"
+			if cold {
+				cache = "cold"
+			}
+			b.Run(fmt.Sprintf("%s/%s", be, cache), func(b *testing.B) {
+				b.SetBytes(info.Size())
+				for i := 0; i < b.N; i++ {
+					if cold {
+						b.StopTimer()
+						dropCache()
+						b.StartTimer()
+					}
+					_, _, err := process(context.Background(), path, 0, BUFFER_SIZE, be, nil)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
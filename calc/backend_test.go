@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testBackends are the backends the golden files are read with. A queue
+// depth of 1 waits for every read, 3 keeps fewer reads in flight than
+// most chunks have buffers
+var testBackends = []backend{
+	preadBackend{},
+	mmapBackend{},
+	uringBackend{depth: 1},
+	uringBackend{depth: 3},
+}
+
+// readChunk reads the bytes of f between from and to with b
+func readChunk(t *testing.T, b backend, f *os.File, from int64, to int64, bufferSize int) ([]byte, error) {
+	t.Helper()
+	r, err := b.open(f, from, to, bufferSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.close()
+
+	var data []byte
+	for {
+		buf, err := r.next()
+		if err == io.EOF {
+			return data, nil
+		} else if err != nil {
+			return data, err
+		}
+		if len(buf) == 0 || len(buf) > bufferSize {
+			t.Fatalf("got a buffer of %d bytes, expected 1 to %d", len(buf), bufferSize)
+		}
+		data = append(data, buf...)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	// more than a page, so that the chunks start in the middle of one
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+	path := filepath.Join(t.TempDir(), "data.txt")
+	err := os.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	chunks := [][2]int64{{0, 0}, {0, 1}, {0, 16000}, {4095, 4097}, {5000, 12345}, {15999, 16000}, {16000, 16000}}
+	for _, b := range testBackends {
+		for _, bufferSize := range []int{1, 7, 4096, BUFFER_SIZE} {
+			for _, c := range chunks {
+				got, err := readChunk(t, b, f, c[0], c[1], bufferSize)
+				if err != nil {
+					t.Fatalf("%s, buffer %d, [%d, %d): %v", b, bufferSize, c[0], c[1], err)
+				}
+				if !bytes.Equal(got, data[c[0]:c[1]]) {
+					t.Errorf("%s, buffer %d, [%d, %d): got %d different bytes", b, bufferSize, c[0], c[1], len(got))
+				}
+			}
+		}
+	}
+}
+
+// TestBackendsTruncated checks that the backends that can tell report a
+// file shorter than when it was split in chunks. mmap cannot: reading
+// past the end of a mapped file is a SIGBUS
+func TestBackendsTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	err := os.WriteFile(path, bytes.Repeat([]byte("x"), 100), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, b := range []backend{preadBackend{}, uringBackend{depth: 1}, uringBackend{depth: 3}} {
+		_, err := readChunk(t, b, f, 50, 150, 16)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: got %v for a truncated file", b, err)
+		}
+	}
+}
+
+func TestParseBackend(t *testing.T) {
+	for _, name := range []string{"pread", "mmap", "uring"} {
+		_, err := parseBackend(name, URING_DEPTH)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, bad := range []struct {
+		name  string
+		depth int
+	}{{"uring", 0}, {"read", URING_DEPTH}, {"", URING_DEPTH}} {
+		_, err := parseBackend(bad.name, bad.depth)
+		if err == nil {
+			t.Errorf("%q with a queue depth of %d: expected an error", bad.name, bad.depth)
+		}
+	}
+}
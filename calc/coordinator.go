@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// coordinateMain implements "calc coordinate", which computes a file with
+// worker processes instead of goroutines: local ones, started as
+// "calc worker", or remote ones listening with "calc worker -listen",
+// which must see the file at the same path
+func coordinateMain(args []string) {
+	fs := flag.NewFlagSet("coordinate", flag.ExitOnError)
+	workers := fs.Int("workers", runtime.NumCPU(), "number of local worker processes, if there are no remote ones")
+	remote := fs.String("remote", "", "comma separated addresses of remote workers, used instead of local ones")
+	ranges := fs.Int("ranges", 0, "number of ranges the file is split in (default 4 per worker)")
+	retries := fs.Int("retries", 3, "number of times a range is computed again after its worker crashed")
+	bufferSize := fs.Int("buffer", BUFFER_SIZE, "size of the buffer the workers read with")
+	timeout := fs.Duration("timeout", TASK_TIMEOUT, "time after which a worker that has not answered is given up and its range computed again")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s coordinate [ options ] <source path> <dest path>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	start := time.Now()
+
+	c := &coordinator{
+		retries:    *retries,
+		bufferSize: *bufferSize,
+		timeout:    *timeout,
+	}
+	if *remote != "" {
+		for _, addr := range strings.Split(*remote, ",") {
+			c.slots = append(c.slots, dialWorker(strings.TrimSpace(addr)))
+		}
+	} else {
+		exe, err := os.Executable()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for range *workers {
+			c.slots = append(c.slots, startWorker(exe, []string{"worker"}, nil))
+		}
+	}
+	if len(c.slots) == 0 || *ranges < 0 || *retries < 0 || *bufferSize < 1 || *timeout < 0 {
+		log.Fatalln("There must be at least a worker, and the other options cannot be negative")
+	}
+	c.ranges = *ranges
+	if c.ranges == 0 {
+		c.ranges = 4 * len(c.slots)
+	}
+
+	result, format, err := c.run(fs.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	out, err := os.Create(fs.Arg(1))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	w := bufio.NewWriter(out)
+	printResult(w, result)
+	err = w.Flush()
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(fs.Arg(1))
+		log.Fatalln(err)
+	}
+
+	fmt.Println("Input format:", format)
+	fmt.Println(time.Since(start))
+}
+
+// TASK_TIMEOUT is the default time a worker has to answer a task, after
+// which it is considered hung
+const TASK_TIMEOUT = 10 * time.Minute
+
+// workerConn is a connection to a worker process, which computes one
+// task at a time
+type workerConn struct {
+	enc   *gob.Encoder
+	dec   *gob.Decoder
+	close func() error
+	// abort interrupts the reads and writes in progress, from another
+	// goroutine
+	abort func()
+}
+
+// run sends task to the worker and waits for its answer, for at most
+// timeout if it is not 0: a worker that is still connected but does
+// not answer is aborted, and has to be closed like one that crashed
+func (wc *workerConn) run(task rangeTask, timeout time.Duration) ([]*WeatherStationInfo, error) {
+	var timedOut atomic.Bool
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			timedOut.Store(true)
+			wc.abort()
+		})
+		defer timer.Stop()
+	}
+
+	err := wc.enc.Encode(&task)
+	var result rangeResult
+	if err == nil {
+		err = wc.dec.Decode(&result)
+	}
+	if err != nil && timedOut.Load() {
+		return nil, fmt.Errorf("no answer after %v", timeout)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if result.Err != "" {
+		return nil, errors.New(result.Err)
+	}
+	return fromWire(result.Stations), nil
+}
+
+// startWorker returns a function starting a local worker process, which
+// speaks on its standard input and output
+func startWorker(name string, args []string, env []string) func() (*workerConn, error) {
+	return func() (*workerConn, error) {
+		cmd := exec.Command(name, args...)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stderr = os.Stderr
+
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		err = cmd.Start()
+		if err != nil {
+			return nil, err
+		}
+
+		return &workerConn{
+			enc: gob.NewEncoder(stdin),
+			dec: gob.NewDecoder(stdout),
+			close: func() error {
+				stdin.Close()
+				return cmd.Wait()
+			},
+			abort: func() { cmd.Process.Kill() },
+		}, nil
+	}
+}
+
+// dialWorker returns a function connecting to a remote worker
+func dialWorker(addr string) func() (*workerConn, error) {
+	return func() (*workerConn, error) {
+		conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		return &workerConn{
+			enc:   gob.NewEncoder(conn),
+			dec:   gob.NewDecoder(conn),
+			close: conn.Close,
+			abort: func() { conn.Close() },
+		}, nil
+	}
+}
+
+// coordinator splits a file in ranges of whole lines and sends them to
+// its slots, each one holding a worker process at a time. A range whose
+// worker crashed goes back in the queue, and the slot starts a new one;
+// a slot that cannot start a worker is given up
+type coordinator struct {
+	slots      []func() (*workerConn, error)
+	ranges     int
+	retries    int
+	bufferSize int
+	timeout    time.Duration // of a task, or 0
+}
+
+type queuedTask struct {
+	rangeTask
+	index    int
+	attempts int
+}
+
+func (c *coordinator) run(path string) ([]*WeatherStationInfo, inputFormat, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, inputFormat{}, err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return nil, inputFormat{}, err
+	}
+	format, err := detectFormat(in, info.Size())
+	if err != nil {
+		return nil, format, err
+	}
+	bounds, err := lineBoundaries(in, format.start(), info.Size(), c.ranges)
+	if err != nil {
+		return nil, format, err
+	}
+
+	// requeued tasks never block, as there are never more of them than
+	// ranges
+	tasks := make(chan queuedTask, len(bounds)-1)
+	for i := range len(bounds) - 1 {
+		tasks <- queuedTask{
+			rangeTask: rangeTask{
+				Path:       path,
+				From:       bounds[i],
+				To:         bounds[i+1],
+				CRLF:       format.crlf,
+				BufferSize: c.bufferSize,
+			},
+			index: i,
+		}
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	partials := make([][]*WeatherStationInfo, len(bounds)-1)
+	remaining := len(partials)
+	alive := len(c.slots)
+	var mu sync.Mutex
+	if remaining == 0 {
+		cancel(nil)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(c.slots))
+	for _, start := range c.slots {
+		go func() {
+			defer wg.Done()
+
+			var wc *workerConn
+			defer func() {
+				if wc != nil {
+					wc.close()
+				}
+			}()
+
+			for {
+				var t queuedTask
+				select {
+				case t = <-tasks:
+				case <-ctx.Done():
+					return
+				}
+
+				if wc == nil {
+					var err error
+					wc, err = start()
+					if err != nil {
+						log.Println("Giving up a worker:", err)
+						tasks <- t
+
+						mu.Lock()
+						alive--
+						if alive == 0 {
+							cancel(fmt.Errorf("no worker left: %w", err))
+						}
+						mu.Unlock()
+						return
+					}
+				}
+
+				partial, err := wc.run(t.rangeTask, c.timeout)
+				if err != nil {
+					log.Printf("Range %d-%d failed: %v\n", t.From, t.To, err)
+					wc.close()
+					wc = nil
+
+					t.attempts++
+					if t.attempts > c.retries {
+						cancel(fmt.Errorf("range %d-%d failed %d times: %w", t.From, t.To, t.attempts, err))
+						return
+					}
+					tasks <- t
+					continue
+				}
+
+				mu.Lock()
+				partials[t.index] = partial
+				remaining--
+				if remaining == 0 {
+					cancel(nil)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); !errors.Is(err, context.Canceled) {
+		return nil, format, err
+	}
+	if len(partials) == 0 {
+		return nil, format, nil
+	}
+	return mergeMatrix(partials), format, nil
+}
+
+// lineBoundaries splits the bytes of f between start and size in n ranges
+// of roughly equal size, moving every boundary to the start of the next
+// line. Empty ranges are left out, so there may be fewer of them
+func lineBoundaries(f io.ReaderAt, start int64, size int64, n int) ([]int64, error) {
+	bounds := []int64{start}
+	buf := make([]byte, 4096)
+
+	for i := 1; i <= n; i++ {
+		b := start + (size-start)*int64(i)/int64(n)
+		if b <= bounds[len(bounds)-1] {
+			continue
+		}
+
+		// b is the start of a line if the previous byte is a newline
+		for pos := b - 1; pos < size; pos += int64(len(buf)) {
+			read, err := f.ReadAt(buf, pos)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			if j := bytes.IndexByte(buf[:read], '\n'); j >= 0 {
+				b = pos + int64(j) + 1
+				break
+			}
+			b = size
+		}
+
+		if b > bounds[len(bounds)-1] {
+			bounds = append(bounds, b)
+		}
+	}
+
+	return bounds, nil
+}
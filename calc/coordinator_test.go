@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWorkerProcess is not a test: it is the worker process started by
+// the coordinators of the other tests, which set CALC_WORKER
+func TestWorkerProcess(t *testing.T) {
+	if os.Getenv("CALC_WORKER") == "" {
+		t.Skip("only run as a worker process")
+	}
+
+	var in io.Reader = os.Stdin
+	if marker := os.Getenv("CALC_WORKER_CRASH"); marker != "" {
+		in = crashOnce{in, marker}
+	}
+	err := serveWorker(in, os.Stdout, "")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// crashOnce exits the process as soon as it reads a task, if it is the
+// first one to create the marker directory
+type crashOnce struct {
+	r      io.Reader
+	marker string
+}
+
+func (c crashOnce) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 && os.Mkdir(c.marker, 0755) == nil {
+		os.Exit(2)
+	}
+	return n, err
+}
+
+// localSlots starts the workers as the test binary itself
+func localSlots(n int, env ...string) []func() (*workerConn, error) {
+	slots := make([]func() (*workerConn, error), n)
+	for i := range slots {
+		slots[i] = startWorker(os.Args[0], []string{"-test.run=^TestWorkerProcess$"}, append([]string{"CALC_WORKER=1"}, env...))
+	}
+	return slots
+}
+
+func coordinateGolden(t *testing.T, c *coordinator, name string) {
+	want, err := os.ReadFile(filepath.Join(goldenDir, name+"-result.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, _, err := c.run(filepath.Join(goldenDir, name+".txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	printResult(&got, result)
+	if diff := diffResults(got.String(), string(want)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCoordinate(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(goldenDir, "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &coordinator{slots: localSlots(2), ranges: 5, bufferSize: 64}
+	for _, inPath := range inputs {
+		if strings.HasSuffix(inPath, "-result.txt") {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(inPath), ".txt")
+
+		t.Run(name, func(t *testing.T) {
+			coordinateGolden(t, c, name)
+		})
+	}
+}
+
+func TestCoordinateCrash(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "crashed")
+	c := &coordinator{slots: localSlots(2, "CALC_WORKER_CRASH="+marker), ranges: 8, retries: 1, bufferSize: 4096}
+	coordinateGolden(t, c, "unique-10000")
+
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("no worker crashed")
+	}
+}
+
+func TestCoordinateRemote(t *testing.T) {
+	var slots []func() (*workerConn, error)
+	for range 2 {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		go serveWorkerConns(l, "")
+		slots = append(slots, dialWorker(l.Addr().String()))
+	}
+
+	// a worker that is down is given up, and its ranges go to the others
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead.Close()
+	slots = append(slots, dialWorker(dead.Addr().String()))
+
+	c := &coordinator{slots: slots, ranges: 16, retries: 0, bufferSize: BUFFER_SIZE}
+	coordinateGolden(t, c, "unique-10000")
+
+	c.slots = slots[2:]
+	_, _, err = c.run(filepath.Join(goldenDir, "unique-10000.txt"))
+	if err == nil || !strings.Contains(err.Error(), "no worker left") {
+		t.Errorf("got %v without workers", err)
+	}
+}
+
+func TestCoordinateTimeout(t *testing.T) {
+	// the first connection to this worker is accepted but never answered
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		hung, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer hung.Close()
+		serveWorkerConns(l, "")
+	}()
+
+	c := &coordinator{slots: []func() (*workerConn, error){dialWorker(l.Addr().String())}, ranges: 4, retries: 1, bufferSize: BUFFER_SIZE, timeout: 200 * time.Millisecond}
+	coordinateGolden(t, c, "unique-10000")
+}
+
+func TestWorkerRoot(t *testing.T) {
+	root, err := filepath.Abs(goldenDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := rangeTask{Path: filepath.Join(root, "unique-10000.txt"), BufferSize: 64}
+	_, err = computeRange(task, root)
+	if err != nil {
+		t.Error(err)
+	}
+
+	task.Path = filepath.Join(root, "..", "..", "README.md")
+	_, err = computeRange(task, root)
+	if !errors.Is(err, errOutsideRoot) {
+		t.Errorf("got %v for a file outside of the root", err)
+	}
+	_, err = computeRange(task, "")
+	if err != nil {
+		t.Errorf("got %v without a root", err)
+	}
+}
+
+func TestLineBoundaries(t *testing.T) {
+	data := []byte("\xEF\xBB\xBFa;1.0\nbb;2.0\n" + strings.Repeat("c", 5000) + ";3.0\nd;4.0")
+	start := int64(len(utf8BOM))
+
+	for n := 1; n <= 20; n++ {
+		bounds, err := lineBoundaries(bytes.NewReader(data), start, int64(len(data)), n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if bounds[0] != start || bounds[len(bounds)-1] != int64(len(data)) {
+			t.Errorf("n=%d: got %v, which do not cover the file", n, bounds)
+		}
+		for i, b := range bounds[1 : len(bounds)-1] {
+			if b <= bounds[i] || data[b-1] != '\n' {
+				t.Errorf("n=%d: boundary %d is not the start of a line after the previous one", n, b)
+			}
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// FLUSH_LINES is the number of lines a connection adds up on its own
+	// before merging them into the shared table. It also merges them as
+	// soon as it has nothing left to read
+	FLUSH_LINES = 4096
+
+	// FLUSH_INTERVAL is how long the UDP reader keeps lines on its own
+	// when no datagram arrives
+	FLUSH_INTERVAL = 50 * time.Millisecond
+
+	// MAX_DATAGRAM_SIZE is the largest UDP payload
+	MAX_DATAGRAM_SIZE = 65535
+)
+
+// daemonMain implements "calc daemon", which adds up the lines received
+// over TCP connections and UDP datagrams until it is stopped, writing the
+// result file every interval and once more before exiting. The query
+// address answers one command per line:
+//
+//	GET <name>  the result of a station, like "<name>=<min>/<mean>/<max>"
+//	ALL         the result of every station, in the format of the challenge
+//	STATS       the number of lines, malformed lines, dropped lines and stations
+func daemonMain(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	tcpAddr := fs.String("tcp", ":9000", "address to accept TCP connections on, or empty")
+	udpAddr := fs.String("udp", ":9000", "address to receive UDP datagrams on, or empty")
+	queryAddr := fs.String("query", ":9001", "address to answer the queries on, or empty")
+	outPath := fs.String("o", "result.txt", "path of the result file")
+	interval := fs.Duration("interval", 10*time.Second, "how often the result file is written")
+	shards := fs.Int("shards", 64, "number of shards of the table of the stations")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s daemon [ options ]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *shards < 1 || *interval <= 0 {
+		log.Fatalln("The number of shards and the interval must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d := newDaemon(*shards)
+	var closers []io.Closer
+	if *tcpAddr != "" {
+		l, err := net.Listen("tcp", *tcpAddr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		closers = append(closers, l)
+		d.goServe(func() { d.serveTCP(l) })
+	}
+	if *udpAddr != "" {
+		c, err := net.ListenPacket("udp", *udpAddr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		closers = append(closers, c)
+		d.goServe(func() { d.serveUDP(c) })
+	}
+	if *queryAddr != "" {
+		l, err := net.Listen("tcp", *queryAddr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		closers = append(closers, l)
+		d.goServe(func() { d.serveQueries(l) })
+	}
+	log.Printf("Receiving on tcp %q and udp %q, answering queries on %q\n", *tcpAddr, *udpAddr, *queryAddr)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-ticker.C:
+			err := d.writeResultFile(*outPath)
+			if err != nil {
+				log.Println(err)
+			}
+		case <-ctx.Done():
+			running = false
+		}
+	}
+
+	// the lines already received are kept, the connections cut
+	for _, c := range closers {
+		c.Close()
+	}
+	d.closeConns()
+	d.wg.Wait()
+
+	err := d.writeResultFile(*outPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Stopped after %s, written result at <%s>\n", d.stats(), *outPath)
+}
+
+// daemon holds the stations received so far, in a table split in shards
+// by the hash of their names: every connection adds up its lines on its
+// own and merges them into the shards, so that the connections only
+// contend for the shards of the same stations
+type daemon struct {
+	shards []tableShard
+
+	lines     atomic.Int64 // valid lines added up
+	malformed atomic.Int64 // lines that are not a valid measurement
+	dropped   atomic.Int64 // lines cut short by a connection error
+
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+type tableShard struct {
+	mu sync.Mutex
+	a  *Aggregator
+}
+
+func newDaemon(shards int) *daemon {
+	d := &daemon{
+		shards: make([]tableShard, shards),
+		conns:  make(map[net.Conn]struct{}),
+	}
+	for i := range d.shards {
+		d.shards[i].a = NewAggregator()
+	}
+	return d
+}
+
+func (d *daemon) goServe(serve func()) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		serve()
+	}()
+}
+
+// merge adds the stations of a into the table, and resets a. The lines
+// are counted here, so that they are in the table by the time they are
+func (d *daemon) merge(a *Aggregator) {
+	var lines int
+	n := uint64(len(d.shards))
+	for nameHash, y := range a.m {
+		shard := &d.shards[nameHash%n]
+		shard.mu.Lock()
+		if x, ok := shard.a.m[nameHash]; ok {
+			x.merge(y)
+		} else {
+			wsi := *y
+			shard.a.m[nameHash] = &wsi
+		}
+		shard.mu.Unlock()
+		lines += y.count
+	}
+
+	d.lines.Add(int64(lines))
+	a.Reset()
+}
+
+// ingest checks a line, without its '\n', and adds it to a
+func (d *daemon) ingest(a *Aggregator, line []byte) {
+	line = trimCR(line)
+	if len(line) == 0 {
+		return
+	}
+	if checkLine(line) != nil {
+		d.malformed.Add(1)
+		return
+	}
+	a.AddLine(line)
+}
+
+func trimCR(line []byte) []byte {
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		return line[:len(line)-1]
+	}
+	return line
+}
+
+func (d *daemon) serveTCP(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		if !d.track(conn) {
+			conn.Close()
+			return
+		}
+
+		d.goServe(func() {
+			defer d.untrack(conn)
+			d.readLines(conn)
+		})
+	}
+}
+
+// readLines adds up the lines of a connection, merging them into the
+// table whenever the connection has nothing more to read right away
+func (d *daemon) readLines(conn net.Conn) {
+	a := NewAggregator()
+	defer d.merge(a)
+
+	br := bufio.NewReaderSize(conn, 64*1024)
+	pending := 0
+	for {
+		line, err := br.ReadSlice('\n')
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			// far too long for a measurement: skipped up to its end
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = br.ReadSlice('\n')
+			}
+			d.malformed.Add(1)
+		case err == nil:
+			d.ingest(a, line[:len(line)-1])
+		case errors.Is(err, io.EOF):
+			// the last line may have no '\n'
+			d.ingest(a, line)
+		case len(line) > 0:
+			d.dropped.Add(1)
+		}
+		if err != nil {
+			return
+		}
+
+		pending++
+		if pending >= FLUSH_LINES || br.Buffered() == 0 {
+			d.merge(a)
+			pending = 0
+		}
+	}
+}
+
+// serveUDP adds up the lines of the datagrams, each holding one or more
+// whole lines
+func (d *daemon) serveUDP(c net.PacketConn) {
+	a := NewAggregator()
+	defer d.merge(a)
+
+	buf := make([]byte, MAX_DATAGRAM_SIZE)
+	pending := 0
+	for {
+		if pending > 0 {
+			c.SetReadDeadline(time.Now().Add(FLUSH_INTERVAL))
+		} else {
+			c.SetReadDeadline(time.Time{})
+		}
+
+		n, _, err := c.ReadFrom(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			d.merge(a)
+			pending = 0
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		data := buf[:n]
+		for len(data) > 0 {
+			line := data
+			if i := bytes.IndexByte(data, '\n'); i >= 0 {
+				line, data = data[:i], data[i+1:]
+			} else {
+				data = nil
+			}
+			d.ingest(a, line)
+			pending++
+		}
+
+		if pending >= FLUSH_LINES {
+			d.merge(a)
+			pending = 0
+		}
+	}
+}
+
+func (d *daemon) serveQueries(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		if !d.track(conn) {
+			conn.Close()
+			return
+		}
+
+		d.goServe(func() {
+			defer d.untrack(conn)
+			d.answer(conn)
+		})
+	}
+}
+
+// answer replies to the commands of a query connection until it is closed
+func (d *daemon) answer(conn net.Conn) {
+	sc := bufio.NewScanner(conn)
+	out := bufio.NewWriter(conn)
+	for sc.Scan() {
+		command, arg, _ := strings.Cut(strings.TrimSpace(sc.Text()), " ")
+		switch strings.ToUpper(command) {
+		case "GET":
+			if wsi := d.get(arg); wsi != nil {
+				fmt.Fprintf(out, "%s=%s/%s/%s\n", wsi.name, formatTenths(int64(wsi.min)), formatTenths(roundMean(wsi.acc, wsi.count)), formatTenths(int64(wsi.max)))
+			} else {
+				fmt.Fprintf(out, "ERR no station %q\n", arg)
+			}
+		case "ALL":
+			printResult(out, d.results())
+		case "STATS":
+			fmt.Fprintln(out, d.stats())
+		case "":
+			continue
+		default:
+			fmt.Fprintf(out, "ERR unknown command %q\n", command)
+		}
+
+		if out.Flush() != nil {
+			return
+		}
+	}
+}
+
+// get returns a copy of the station with the given name, or nil
+func (d *daemon) get(name string) *WeatherStationInfo {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	nameHash := h.Sum64()
+
+	shard := &d.shards[nameHash%uint64(len(d.shards))]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	wsi, ok := shard.a.m[nameHash]
+	if !ok {
+		return nil
+	}
+	copied := *wsi
+	return &copied
+}
+
+// results returns a snapshot of every station, sorted by name
+func (d *daemon) results() []*WeatherStationInfo {
+	partials := make([][]*WeatherStationInfo, len(d.shards))
+	for i := range d.shards {
+		shard := &d.shards[i]
+		shard.mu.Lock()
+		partials[i] = shard.a.Results()
+		shard.mu.Unlock()
+	}
+	return mergeMatrix(partials)
+}
+
+func (d *daemon) stats() string {
+	var stations int
+	for i := range d.shards {
+		shard := &d.shards[i]
+		shard.mu.Lock()
+		stations += shard.a.Len()
+		shard.mu.Unlock()
+	}
+	return fmt.Sprintf("lines=%d malformed=%d dropped=%d stations=%d", d.lines.Load(), d.malformed.Load(), d.dropped.Load(), stations)
+}
+
+// writeResultFile replaces the file at path with the current result, by
+// renaming a complete temporary file over it
+func (d *daemon) writeResultFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	out := bufio.NewWriter(f)
+	printResult(out, d.results())
+	err = out.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// track records an open connection, unless the daemon is stopping
+func (d *daemon) track(conn net.Conn) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conns == nil {
+		return false
+	}
+	d.conns[conn] = struct{}{}
+	return true
+}
+
+func (d *daemon) untrack(conn net.Conn) {
+	d.mu.Lock()
+	delete(d.conns, conn)
+	d.mu.Unlock()
+	conn.Close()
+}
+
+// closeConns cuts every open connection and refuses the next ones
+func (d *daemon) closeConns() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for conn := range d.conns {
+		conn.Close()
+	}
+	d.conns = nil
+}
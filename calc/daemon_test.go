@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemon(t *testing.T) {
+	d := newDaemon(4)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.goServe(func() { d.serveTCP(tcp) })
+	d.goServe(func() { d.serveUDP(udp) })
+	d.goServe(func() { d.serveQueries(query) })
+	defer func() {
+		tcp.Close()
+		udp.Close()
+		query.Close()
+		d.closeConns()
+		d.wg.Wait()
+	}()
+
+	// the same lines split between connections, with CRLF on some
+	data := fuzzMeasurements(bytes.Repeat([]byte{7, 200, 13, 0, 255, 1, 42}, 1000))
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	var all strings.Builder
+	for c := range 3 {
+		conn, err := net.Dial("tcp", tcp.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sent strings.Builder
+		for i := c; i < len(lines); i += 3 {
+			line := strings.TrimSuffix(lines[i], "\n")
+			all.WriteString(line + "\n")
+			if c == 1 {
+				line += "\r"
+			}
+			sent.WriteString(line + "\n")
+		}
+		sent.WriteString("malformed line\n" + strings.Repeat("x", 100*1024) + "\n")
+		fmt.Fprint(conn, sent.String())
+		conn.Close()
+	}
+
+	conn, err := net.Dial("udp", udp.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 20 {
+		datagram := fmt.Sprintf("udp;%d.5\nudp;-%d.5\nudp;1.0.0", i, i)
+		conn.Write([]byte(datagram))
+		all.WriteString(fmt.Sprintf("udp;%d.5\nudp;-%d.5\n", i, i))
+	}
+	conn.Close()
+
+	q, err := net.Dial("tcp", query.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	replies := bufio.NewReader(q)
+	ask := func(command string) string {
+		fmt.Fprintln(q, command)
+		reply, err := replies.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSuffix(reply, "\n")
+	}
+
+	wantStats := fmt.Sprintf("lines=%d malformed=%d dropped=0", len(lines)+40, 6+20)
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		stats := ask("STATS")
+		if strings.HasPrefix(stats, wantStats+" ") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %s, want %s", stats, wantStats)
+		}
+	}
+
+	var want bytes.Buffer
+	printResult(&want, referenceResult([]byte(all.String())))
+
+	fmt.Fprintln(q, "ALL")
+	var got strings.Builder
+	for !strings.HasSuffix(got.String(), "}\n") {
+		line, err := replies.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		got.WriteString(line)
+	}
+	if diff := diffResults(got.String(), want.String()); diff != "" {
+		t.Error(diff)
+	}
+
+	if reply := ask("get udp"); reply != "udp=-19.5/0.0/19.5" {
+		t.Errorf("got %q for a station", reply)
+	}
+	if reply := ask("GET nowhere"); !strings.HasPrefix(reply, "ERR") {
+		t.Errorf("got %q for a missing station", reply)
+	}
+	if reply := ask("DELETE udp"); !strings.HasPrefix(reply, "ERR") {
+		t.Errorf("got %q for an unknown command", reply)
+	}
+
+	path := filepath.Join(t.TempDir(), "result.txt")
+	for range 2 {
+		err = d.writeResultFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := diffResults(string(written), want.String()); diff != "" {
+		t.Error(diff)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("got %d files next to the result, want none", len(entries)-1)
+	}
+}
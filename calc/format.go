@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// utf8BOM is the byte order mark some Windows tools put at the start
+// of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// inputFormat describes the variant of the measurements file, which is
+// detected once before starting the workers so that plain LF files do
+// not pay for the others
+type inputFormat struct {
+	bom             bool // the file starts with a UTF-8 byte order mark
+	crlf            bool // lines end with "\r\n" instead of "\n"
+	trailingNewline bool // the last line ends with a newline
+}
+
+// detectFormat looks at the first line and at the last byte of f, which
+// is size bytes long
+func detectFormat(f *os.File, size int64) (inputFormat, error) {
+	var format inputFormat
+	if size == 0 {
+		format.trailingNewline = true
+		return format, nil
+	}
+
+	// enough for the BOM and the longest line allowed
+	var buf [128]byte
+	n, err := f.ReadAt(buf[:], 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return format, err
+	}
+	head := buf[:n]
+
+	format.bom = bytes.HasPrefix(head, utf8BOM)
+	if i := bytes.IndexByte(head, '\n'); i > 0 {
+		format.crlf = head[i-1] == '\r'
+	}
+
+	_, err = f.ReadAt(buf[:1], size-1)
+	if err != nil {
+		return format, err
+	}
+	format.trailingNewline = buf[0] == '\n'
+
+	return format, nil
+}
+
+// start returns the offset of the first measurement
+func (format inputFormat) start() int64 {
+	if format.bom {
+		return int64(len(utf8BOM))
+	}
+	return 0
+}
+
+func (format inputFormat) String() string {
+	var sb strings.Builder
+	if format.crlf {
+		sb.WriteString("CRLF")
+	} else {
+		sb.WriteString("LF")
+	}
+	if format.bom {
+		sb.WriteString(", BOM")
+	}
+	if !format.trailingNewline {
+		sb.WriteString(", no trailing newline")
+	}
+	return sb.String()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]string{
+		"empty":                        "LF",
+		"single-line":                  "LF",
+		"no-trailing-newline":          "LF, no trailing newline",
+		"crlf":                         "CRLF",
+		"bom":                          "LF, BOM",
+		"crlf-bom-no-trailing-newline": "CRLF, BOM, no trailing newline",
+	}
+
+	for name, want := range tests {
+		_, format, err := process(context.Background(), filepath.Join(goldenDir, name+".txt"), 1, BUFFER_SIZE, preadBackend{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := format.String(); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func FuzzCompute(f *testing.F) {
+	f.Add([]byte("Hamburg;12.0"), uint8(1), uint16(64))
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, uint8(3), uint16(1))
+	f.Add(bytes.Repeat([]byte{7, 200, 13, 0}, 100), uint8(8), uint16(16))
+	f.Add(bytes.Repeat([]byte{255, 0, 1}, 500), uint8(64), uint16(100))
+
+	f.Fuzz(func(t *testing.T, seed []byte, workers uint8, bufferSize uint16) {
+		data := fuzzMeasurements(seed)
+
+		path := filepath.Join(t.TempDir(), "measurements.txt")
+		err := os.WriteFile(path, data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got, want bytes.Buffer
+		result, _, err := process(context.Background(), path, int(workers)%65+1, int(bufferSize)%4096+1, preadBackend{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		printResult(&got, result)
+		printResult(&want, referenceResult(data))
+
+		if diff := diffResults(got.String(), want.String()); diff != "" {
+			t.Errorf("workers=%d buffer=%d: %s\n%q", int(workers)%65+1, int(bufferSize)%4096+1, diff, data)
+		}
+	})
+}
+
+func FuzzParseLine(f *testing.F) {
+	f.Add([]byte("Hamburg;12.0"))
+	f.Add([]byte("Hamburg;-99.9"))
+	f.Add([]byte(";"))
+	f.Add([]byte("-"))
+	f.Add([]byte("no separator"))
+	f.Add([]byte("a;b;c;1..2--3"))
+
+	f.Fuzz(func(t *testing.T, line []byte) {
+		m := make(map[uint64]*WeatherStationInfo)
+		parseLine(line, false, fnv.New64a(), m)
+		parseLine(line, true, fnv.New64a(), m)
+		computeChunk(line, true, fnv.New64a(), m)
+	})
+}
+
+// fuzzMeasurements turns arbitrary bytes into valid measurements: every
+// 3 bytes become a line, with the name taken from a small alphabet of 1
+// and 2 bytes characters so that stations repeat. If the length of seed
+// is odd, the last line has no trailing newline
+func fuzzMeasurements(seed []byte) []byte {
+	alphabet := []string{"a", "b", "é", "東"}
+
+	var b []byte
+	for i := 0; i+2 < len(seed); i += 3 {
+		for j := range seed[i]%3 + 1 {
+			b = append(b, alphabet[(seed[i]>>(2*j))%4]...)
+		}
+		b = append(b, ';')
+
+		temp := int(int16(seed[i+1])<<8|int16(seed[i+2])) % 1000
+		b = append(b, formatTenths(int64(temp))...)
+		b = append(b, '\n')
+	}
+
+	if len(b) > 0 && len(seed)%2 == 1 {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// referenceResult is a trivial line by line implementation of the
+// challenge, used to check the results of process
+func referenceResult(data []byte) []*WeatherStationInfo {
+	m := make(map[string]*WeatherStationInfo)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+
+		name, tempString, _ := strings.Cut(line, ";")
+		t, err := strconv.Atoi(strings.Replace(tempString, ".", "", 1))
+		if err != nil {
+			panic(err)
+		}
+		temp := int16(t)
+
+		wsi, ok := m[name]
+		if !ok {
+			m[name] = &WeatherStationInfo{
+				name: name,
+				min:  temp, max: temp,
+				acc: int64(temp), count: 1,
+			}
+			continue
+		}
+		wsi.min = min(wsi.min, temp)
+		wsi.max = max(wsi.max, temp)
+		wsi.acc += int64(temp)
+		wsi.count++
+	}
+
+	result := make([]*WeatherStationInfo, 0, len(m))
+	for _, wsi := range m {
+		result = append(result, wsi)
+	}
+	slices.SortFunc(result, (*WeatherStationInfo).Compare)
+	return result
+}
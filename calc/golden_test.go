@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// goldenDir holds the corpus shared by every implementation, generated
+// by the create module: each case is made of <name>.txt and the expected
+// <name>-result.txt
+const goldenDir = "../testdata/golden"
+
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(goldenDir, "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, inPath := range inputs {
+		if strings.HasSuffix(inPath, "-result.txt") {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(inPath), ".txt")
+
+		want, err := os.ReadFile(filepath.Join(goldenDir, name+"-result.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the boundary cases are 1024 bytes long, so most of these
+		// split them exactly at the lines they are built around
+		for _, workers := range []int{1, 2, 3, 4, 8, 64} {
+			for _, bufferSize := range []int{1, 16, 64, 4096, BUFFER_SIZE} {
+				for _, b := range testBackends {
+					t.Run(fmt.Sprintf("%s/workers=%d/buffer=%d/%s", name, workers, bufferSize, b), func(t *testing.T) {
+						if bufferSize == 1 && len(want) > 4096 {
+							t.Skip("too slow with a single byte buffer")
+						}
+
+						var out bytes.Buffer
+						result, _, err := process(context.Background(), inPath, workers, bufferSize, b, nil)
+						if err != nil {
+							t.Fatal(err)
+						}
+						printResult(&out, result)
+
+						if diff := diffResults(out.String(), string(want)); diff != "" {
+							t.Error(diff)
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+// diffResults returns a description of the first line where got differs
+// from want, or an empty string if they are the same
+func diffResults(got string, want string) string {
+	if got == want {
+		return ""
+	}
+
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+	for i := range min(len(gotLines), len(wantLines)) {
+		if gotLines[i] != wantLines[i] {
+			return fmt.Sprintf("line %d: got %q, want %q", i+1, gotLines[i], wantLines[i])
+		}
+	}
+	return fmt.Sprintf("got %d lines, want %d", len(gotLines), len(wantLines))
+}
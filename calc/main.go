@@ -1,17 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"hash"
 	"hash/fnv"
 	"io"
 	"log"
 	"os"
-	"runtime"
+	"os/signal"
 	"runtime/pprof"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/nixpare/sorting"
@@ -34,16 +39,108 @@ func (wsi *WeatherStationInfo) Compare(other *WeatherStationInfo) int {
 	return strings.Compare(wsi.name, other.name)
 }
 
+func (wsi *WeatherStationInfo) add(temp int16) {
+	if temp < wsi.min {
+		wsi.min = temp
+	}
+	if temp > wsi.max {
+		wsi.max = temp
+	}
+	wsi.acc += int64(temp)
+	wsi.count++
+}
+
+// merge adds the values of other, a partial result of the same station
+func (wsi *WeatherStationInfo) merge(other *WeatherStationInfo) {
+	if other.min < wsi.min {
+		wsi.min = other.min
+	}
+	if other.max > wsi.max {
+		wsi.max = other.max
+	}
+	wsi.acc += other.acc
+	wsi.count += other.count
+}
+
 func main() {
-	if len(os.Args) > 3 && os.Args[3] == "profile" {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serveMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "daemon" {
+		daemonMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "coordinate" {
+		coordinateMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		workerMain(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		mergeMain(os.Args[2:])
+		return
+	}
+
+	// the deferred functions of run, like the one stopping the profile,
+	// must run before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:])
+	if err != nil && ctx.Err() != nil {
+		err = errors.New("interrupted")
+	}
+	stop()
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// run computes a file as asked by the command line, without the name of
+// the program. If it fails or ctx is cancelled, it removes the output it
+// has already written
+func run(ctx context.Context, cmdArgs []string) (err error) {
+	fs := flag.NewFlagSet("calc", flag.ExitOnError)
+	partialOut := fs.String("partial-out", "", "also write the stations as a partial file, to be combined with others by \"calc merge\"")
+	showProgress := fs.Bool("progress", false, "report the progress on the standard error: updated in place on a terminal, logged every " + PROGRESS_LOG_INTERVAL.String() + " otherwise")
+	memory := fs.String("memory", "", "budget for the tables of the workers, like 512MiB: beyond it, they are spilled to sorted runs on disk (default no limit)")
+	spillDir := fs.String("spill-dir", os.TempDir(), "directory of the runs spilled with -memory")
+	backendName := fs.String("io", "pread", "how the workers read the file: pread, mmap or uring, which falls back to pread where io_uring is not available")
+	depth := fs.Int("queue-depth", URING_DEPTH, "number of reads every worker keeps in flight with -io uring")
+	fs.Parse(cmdArgs)
+	args := fs.Args()
+
+	if len(args) < 2 {
+		return errors.New("required source and dest path")
+	}
+	b, err := parseBackend(*backendName, *depth)
+	if err != nil {
+		return err
+	}
+	var budget int64
+	if *memory != "" {
+		budget, err = parseSize(*memory)
+		if err != nil {
+			return err
+		}
+		if *partialOut != "" {
+			return errors.New("-partial-out cannot be used with -memory, as the result is never entirely in memory")
+		}
+	}
+
+	if len(args) > 2 && args[2] == "profile" {
 		f, err := os.Create("default.pgo")
 		if err != nil {
-			log.Fatalln(err)
+			return err
 		}
+		defer f.Close()
 
 		err = pprof.StartCPUProfile(f)
 		if err != nil {
-			log.Fatalln(err)
+			return err
 		}
 
 		defer pprof.StopCPUProfile()
@@ -51,153 +148,241 @@ func main() {
 	
 	start := time.Now()
 
-	if len(os.Args) < 3 {
-		log.Fatalln("Required source and dest path")
+	out, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := out.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(args[1])
+		}
+	}()
+
+	var p *progress
+	stopProgress := func() {}
+	if *showProgress {
+		total, err := dataSize(args[0])
+		if err != nil {
+			return err
+		}
+		p = &progress{}
+		stopProgress = p.report(os.Stderr, total)
+	}
+
+	var result []*WeatherStationInfo
+	var format inputFormat
+	w := bufio.NewWriter(out)
+	if budget > 0 {
+		format, err = printResultSpilling(ctx, w, args[0], b, p, *spillDir, budget)
+	} else {
+		result, format, err = process(ctx, args[0], 0, BUFFER_SIZE, b, p)
+		if err == nil {
+			printResult(w, result)
+		}
+	}
+	stopProgress()
+	if err != nil {
+		return err
 	}
 
-	out, err := os.Create(os.Args[2])
+	err = w.Flush()
 	if err != nil {
-		log.Fatalln(err)
+		return err
+	}
+
+	if *partialOut != "" {
+		err = writePartialFile(*partialOut, result)
+		if err != nil {
+			return err
+		}
 	}
-	defer out.Close()
 
-    inFilePath := os.Args[1]
+	fmt.Println("Input format:", format)
+
+	end := time.Since(start)
+	fmt.Println(end)
+	return nil
+}
+
+// overflow holds the bytes of a chunk that could not be parsed by its
+// worker because they belong to lines shared with the previous or the
+// next chunk
+type overflow struct {
+	head  []byte // bytes before the first '\n' of the chunk
+	tail  []byte // bytes after the last '\n' of the chunk
+	whole bool   // the chunk has no '\n' at all, so head is the entire chunk
+}
 
-	inInfo, err := os.Stat(inFilePath)
+// process splits the file in one chunk per worker, computes every chunk
+// reading bufferSize bytes at a time with b and merges the partial
+// results. If workers is not positive, it is chosen by b based on the
+// number of CPUs and the size of the file. If p is not nil, the workers count on it
+// what they read. It also returns the detected input format.
+// The first worker to fail cancels the others, and its error is returned,
+// as is the cause of ctx if it is cancelled
+func process(ctx context.Context, inFilePath string, workers int, bufferSize int, b backend, p *progress) ([]*WeatherStationInfo, inputFormat, error) {
+	partials, format, err := processChunks(ctx, inFilePath, workers, bufferSize, b, p, nil)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, format, err
 	}
+	return mergeMatrix(partials), format, nil
+}
 
-	fileSize := inInfo.Size()
-	workers := runtime.NumCPU() * WORKERS_MULTIPLIER
+// processChunks is process without the final merge: it returns the
+// partial results of the workers, followed by the one of the lines they
+// share. If sp is not nil, the workers spill their tables with it
+func processChunks(ctx context.Context, inFilePath string, workers int, bufferSize int, b backend, p *progress, sp *spiller) ([][]*WeatherStationInfo, inputFormat, error) {
+	in, err := os.Open(inFilePath)
+	if err != nil {
+		return nil, inputFormat{}, err
+	}
+	defer in.Close()
 
-	if fileSize < BUFFER_SIZE {
-		workers = 1
+	inInfo, err := in.Stat()
+	if err != nil {
+		return nil, inputFormat{}, err
 	}
 
-	var chunkSize int64
-	if workers > 1 {
-		chunkSize = fileSize / int64(workers-1)
-	} else {
-		chunkSize = fileSize
+	fileSize := inInfo.Size()
+	format, err := detectFormat(in, fileSize)
+	if err != nil {
+		return nil, format, err
+	}
+	if workers <= 0 {
+		workers = b.workers(fileSize)
+	}
+	if sp != nil {
+		sp.setWorkers(workers)
 	}
 
 	partials := make([][]*WeatherStationInfo, workers+1)
-	overflows := make([][]byte, workers*2-2)
+	overflows := make([]overflow, workers)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
 	var wg sync.WaitGroup
 	wg.Add(workers)
+	dataStart := format.start()
+	dataSize := fileSize - dataStart
 	for i := range workers {
-		from := chunkSize * int64(i)
-		to := from + chunkSize
-		if to > fileSize {
-			to = fileSize
-		}
+		from := dataStart + dataSize * int64(i) / int64(workers)
+		to := dataStart + dataSize * int64(i+1) / int64(workers)
 
 		go func() {
 			defer wg.Done()
-			partials[i] = compute(inFilePath, from, to, i, workers, overflows)
+
+			r, err := b.open(in, from, to, bufferSize)
+			if err == nil {
+				partials[i], err = compute(ctx, r, format.crlf, &overflows[i], p, sp)
+				r.close()
+			}
+			if err != nil {
+				cancel(err)
+			}
 		}()
 	}
 	wg.Wait()
 
-    leftover := make([]byte, 0, 128)
-    for i := 0; i < len(overflows); i += 2 {
-        leftover = append(leftover, overflows[i]...)
-        leftover = append(leftover, overflows[i+1]...)
-        leftover = append(leftover, '\n')
-    }
-
-	leftoverM := make(map[uint64]*WeatherStationInfo)
-	h := fnv.New64a()
-
-	computeChunk(leftover, h, leftoverM)
-	partials[len(partials)-1] = sortedValues(leftoverM)
+	if err := context.Cause(ctx); err != nil {
+		return nil, format, err
+	}
 
-	result := mergeMatrix(partials)
-	printResult(out, result)
+	partials[len(partials)-1] = computeOverflows(overflows, format.crlf)
 
-	end := time.Since(start)
-	fmt.Println(end)
+	return partials, format, nil
 }
 
-func compute(filePath string, from int64, to int64, workerID int, workers int, overflows [][]byte) []*WeatherStationInfo {
-	if from == to {
-		return nil
+// computeOverflows computes the lines shared by consecutive chunks, given
+// their overflows in order
+func computeOverflows(overflows []overflow, crlf bool) []*WeatherStationInfo {
+	leftover := make([]byte, 0, 128)
+	for _, of := range overflows {
+		leftover = append(leftover, of.head...)
+		if !of.whole {
+			leftover = append(leftover, '\n')
+			leftover = append(leftover, of.tail...)
+		}
 	}
+	// the file may not end with a newline
+	leftover = append(leftover, '\n')
 
-	m := make(map[uint64]*WeatherStationInfo)
+	leftoverM := make(map[uint64]*WeatherStationInfo)
 	h := fnv.New64a()
 
-	f, err := os.OpenFile(filePath, os.O_RDONLY, 0)
-	if err != nil {
-		panic(err)
-	}
-	defer f.Close()
+	computeChunk(leftover, crlf, h, leftoverM)
+	return sortedValues(leftoverM)
+}
 
-	_, err = f.Seek(from, io.SeekStart)
-	if err != nil {
-		panic(err)
+// compute computes the lines of a chunk read by r, leaving in of the
+// bytes shared with the others. It stops with the error of ctx once it is
+// cancelled, checking it before every buffer. If sp is not nil, the table
+// is spilled with it whenever it grows past its budget after a buffer,
+// and only the stations since the last spill are returned
+func compute(ctx context.Context, r chunkReader, crlf bool, of *overflow, p *progress, sp *spiller) ([]*WeatherStationInfo, error) {
+	of.whole = true
+	if p != nil {
+		p.workers.Add(1)
+		defer p.workers.Add(-1)
 	}
 
-	var buf [BUFFER_SIZE]byte
-	var _leftover [128]byte
-	leftover := _leftover[:0:128]
+	m := make(map[uint64]*WeatherStationInfo)
+	h := fnv.New64a()
 
-	times := (to - from) / BUFFER_SIZE
-	var read int
+	leftover := make([]byte, 0, 128)
 
-	for i := range times + 1 {
-		var size int64 = BUFFER_SIZE
-		if i == times && int64(read)+BUFFER_SIZE > to-from {
-			size = to - from - int64(read)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, context.Cause(ctx)
 		}
 
-		fBuf := buf[:size]
-		n, err := f.Read(fBuf)
-		if err != nil && !errors.Is(err, io.EOF) {
-			panic(err)
+		chunk, err := r.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			p.add(chunk)
 		}
-		read += n
 
-		var firstLineIndex int
-		for ; ; firstLineIndex++ {
-			if buf[firstLineIndex] == '\n' {
-				break
+		firstLineIndex := bytes.IndexByte(chunk, '\n')
+		if firstLineIndex == -1 {
+			if of.whole {
+				of.head = append(of.head, chunk...)
+			} else {
+				leftover = append(leftover, chunk...)
 			}
+			continue
 		}
 
-		if workerID != 0 && i == 0 {
-			o := make([]byte, firstLineIndex)
-			copy(o, buf[:firstLineIndex])
-
-			overflows[workerID*2-1] = o
+		if of.whole {
+			of.head = append(of.head, chunk[:firstLineIndex]...)
+			of.whole = false
 		} else {
-			leftover = append(leftover, buf[:firstLineIndex]...)
-			parseLine(leftover, h, m)
+			leftover = append(leftover, chunk[:firstLineIndex]...)
+			parseLine(leftover, crlf, h, m)
 			leftover = leftover[:0]
 		}
 
-		var lastLineIndex int
-		for lastLineIndex = n - 1; ; lastLineIndex-- {
-			if buf[lastLineIndex] == '\n' {
-				break
-			}
-		}
+		lastLineIndex := bytes.LastIndexByte(chunk, '\n')
+		computeChunk(chunk[firstLineIndex+1:lastLineIndex+1], crlf, h, m)
+		leftover = append(leftover, chunk[lastLineIndex+1:]...)
 
-		if workerID != workers-1 && i == times {
-			o := make([]byte, len(buf)-lastLineIndex+1)
-			copy(o, buf[lastLineIndex+1:])
-
-			overflows[workerID*2] = o
-		} else {
-			leftover = append(leftover, buf[lastLineIndex+1:]...)
+		if sp != nil && len(m) > sp.maxStations {
+			err := sp.spill(m)
+			if err != nil {
+				return nil, err
+			}
 		}
-
-		computeChunk(buf[firstLineIndex+1:lastLineIndex+1], h, m)
 	}
 
-	return sortedValues(m)
+	of.tail = leftover
+	return sortedValues(m), nil
 }
 
 func sortedValues(m map[uint64]*WeatherStationInfo) []*WeatherStationInfo {
@@ -210,17 +395,22 @@ func sortedValues(m map[uint64]*WeatherStationInfo) []*WeatherStationInfo {
     return values
 }
 
-func computeChunk(chunk []byte, h hash.Hash64, m map[uint64]*WeatherStationInfo) {
+func computeChunk(chunk []byte, crlf bool, h hash.Hash64, m map[uint64]*WeatherStationInfo) {
 	var nextStart int
 	for i, b := range chunk {
 		if b == '\n' {
-			parseLine(chunk[nextStart:i], h, m)
+			parseLine(chunk[nextStart:i], crlf, h, m)
 			nextStart = i + 1
 		}
 	}
 }
 
-func parseLine(line []byte, h hash.Hash64, m map[uint64]*WeatherStationInfo) {
+// parseLine adds a line without its '\n' to m. If crlf is set, the line
+// may also end with a '\r' that is ignored
+func parseLine(line []byte, crlf bool, h hash.Hash64, m map[uint64]*WeatherStationInfo) {
+	if crlf && len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
 	if len(line) == 0 {
 		return
 	}
@@ -261,14 +451,7 @@ loop:
 			acc: int64(temp), count: 1,
 		}
 	} else {
-		if temp < wsi.min {
-			wsi.min = temp
-		}
-		if temp > wsi.max {
-			wsi.max = temp
-		}
-		wsi.acc += int64(temp)
-		wsi.count++
+		wsi.add(temp)
 	}
 }
 
@@ -277,12 +460,39 @@ func printResult(out io.Writer, result []*WeatherStationInfo) {
 	first := true
 
 	for _, x := range result {
-		if first {
-			first = false
-			fmt.Fprintf(out, "\t%s=%.1f/%.1f/%.1f", x.name, float32(x.min)/10.0, float64(x.acc)/10.0/float64(x.count), float32(x.max)/10.0)
-		} else {
-			fmt.Fprintf(out, ",\n\t%s=%.1f/%.1f/%.1f", x.name, float32(x.min)/10.0, float64(x.acc)/10.0/float64(x.count), float32(x.max)/10.0)
-		}
+		printStation(out, x, first)
+		first = false
 	}
 	fmt.Fprint(out, "\n}\n")
 }
+
+// printStation prints a station of the result, after the others if it
+// is not the first one
+func printStation(out io.Writer, x *WeatherStationInfo, first bool) {
+	mean := roundMean(x.acc, x.count)
+	if first {
+		fmt.Fprintf(out, "\t%s=%s/%s/%s", x.name, formatTenths(int64(x.min)), formatTenths(mean), formatTenths(int64(x.max)))
+	} else {
+		fmt.Fprintf(out, ",\n\t%s=%s/%s/%s", x.name, formatTenths(int64(x.min)), formatTenths(mean), formatTenths(int64(x.max)))
+	}
+}
+
+// roundMean returns acc / count rounded half up, which is the rule
+// used by the reference implementation (Java's Math.round)
+func roundMean(acc int64, count int) int64 {
+	n, d := 2*acc+int64(count), 2*int64(count)
+	q := n / d
+	if n%d != 0 && n < 0 {
+		q--
+	}
+	return q
+}
+
+// formatTenths prints a value expressed in tenths with exactly one
+// decimal digit, without ever producing "-0.0"
+func formatTenths(t int64) string {
+	if t < 0 {
+		return fmt.Sprintf("-%d.%d", -t/10, -t%10)
+	}
+	return fmt.Sprintf("%d.%d", t/10, t%10)
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -14,3 +19,89 @@ func BenchmarkMain(b *testing.B) {
         main()
     }
 }
+
+func TestComputeErrors(t *testing.T) {
+	path := filepath.Join(goldenDir, "unique-10000.txt")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = process(context.Background(), filepath.Join(t.TempDir(), "missing.txt"), 1, 64, preadBackend{}, nil)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got %v for a missing file", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// a chunk past the end of the file, as if it was truncated meanwhile
+	var of overflow
+	r, _ := preadBackend{}.open(f, info.Size()-10, info.Size()+10, 64)
+	_, err = compute(context.Background(), r, false, &of, nil, nil)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v for a truncated file", err)
+	}
+
+	cause := errors.New("another worker failed")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(cause)
+	r, _ = preadBackend{}.open(f, 0, info.Size(), 64)
+	_, err = compute(ctx, r, false, &of, nil, nil)
+	if err != cause {
+		t.Errorf("got %v once cancelled", err)
+	}
+}
+
+func TestProcessCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := process(ctx, filepath.Join(goldenDir, "unique-10000.txt"), 4, 64, preadBackend{}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRunRemovesOutput(t *testing.T) {
+	dir := t.TempDir()
+	out, part := filepath.Join(dir, "result.txt"), filepath.Join(dir, "result.part")
+	in := filepath.Join(goldenDir, "unique-10000.txt")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		args []string
+		want string
+	}{
+		{"missing input", context.Background(), []string{"-partial-out", part, filepath.Join(dir, "missing.txt"), out}, "no such file"},
+		{"cancelled", ctx, []string{"-partial-out", part, in, out}, "context canceled"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := run(tt.ctx, tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+
+			for _, path := range []string{out, part} {
+				if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("%s was left behind", filepath.Base(path))
+				}
+			}
+		})
+	}
+
+	if err := run(context.Background(), []string{"-partial-out", part, in, out}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{out, part} {
+		if _, err := os.Stat(path); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -8,13 +8,10 @@ func mergeMatrix(partials [][]*WeatherStationInfo) []*WeatherStationInfo {
 
 	result := make([]*WeatherStationInfo, n*2)
 
-	var from int
-	for len(partials) > 1 {
-		if from >= n {
-			from = 0
-		} else {
-			from = n
-		}
+	for round := 0; len(partials) > 1; round++ {
+		// every round writes in the half of result that was not written
+		// by the previous one, which holds the partials being merged
+		from := n * ((round + 1) % 2)
 
 		for i := 0; i+1 < len(partials); i += 2 {
 			a, b := partials[i], partials[i+1]
@@ -27,7 +24,12 @@ func mergeMatrix(partials [][]*WeatherStationInfo) []*WeatherStationInfo {
 		}
 
 		if len(partials) % 2 == 1 {
-			partials[len(partials)/2] = partials[len(partials)-1]
+			// the last partial is moved too: if it was left in the other
+			// half, it would be overwritten by the next round
+			last := partials[len(partials)-1]
+			copy(result[from:], last)
+
+			partials[len(partials)/2] = result[from : from+len(last)]
 			partials = partials[:len(partials)/2+1]
 		} else {
 			partials = partials[:len(partials)/2]
@@ -48,19 +50,8 @@ func mergeMatrixInto(a []*WeatherStationInfo, b []*WeatherStationInfo, into []*W
 			into[k] = b[j]
 			j++
 		case 0:
-			x := a[i]
-			y := b[j]
-
-			if y.min < x.min {
-				x.min = y.min
-			}
-			if y.max > x.max {
-				x.max = y.max
-			}
-			x.acc += y.acc
-			x.count += y.count
-
-			into[k] = x
+			a[i].merge(b[j])
+			into[k] = a[i]
 			i++; j++
 		}
 	}
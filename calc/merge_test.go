@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMergeMatrix(t *testing.T) {
+	// with distinct stations every round fills exactly one half of the
+	// result, and odd numbers of partials carry the last one over
+	for _, n := range []int{1, 2, 3, 7, 8, 13, 52} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			partials := make([][]*WeatherStationInfo, n)
+			for i := range partials {
+				for k := range 3 {
+					partials[i] = append(partials[i], &WeatherStationInfo{
+						name: fmt.Sprintf("%03d", i+k*n),
+						min:  1, max: 1, acc: 1, count: 1,
+					})
+				}
+			}
+
+			result := mergeMatrix(partials)
+			if len(result) != 3*n {
+				t.Fatalf("got %d stations, want %d", len(result), 3*n)
+			}
+			for i, wsi := range result {
+				if wsi.name != fmt.Sprintf("%03d", i) {
+					t.Fatalf("station %d: got %s", i, wsi.name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+func newMmapReader(f *os.File, from int64, to int64, bufferSize int) (chunkReader, error) {
+	return nil, errors.New("the mmap backend is not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// mmapReader gives slices of a mapping of its chunk
+type mmapReader struct {
+	mapping    []byte
+	data       []byte // the chunk, which starts after the page boundary the mapping starts at
+	bufferSize int
+}
+
+func newMmapReader(f *os.File, from int64, to int64, bufferSize int) (chunkReader, error) {
+	start := from &^ int64(os.Getpagesize()-1)
+	mapping, err := syscall.Mmap(int(f.Fd()), start, int(to-start), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: f.Name(), Err: err}
+	}
+	return &mmapReader{mapping: mapping, data: mapping[from-start:], bufferSize: bufferSize}, nil
+}
+
+func (r *mmapReader) next() ([]byte, error) {
+	if len(r.data) == 0 {
+		return nil, io.EOF
+	}
+	n := min(r.bufferSize, len(r.data))
+	chunk := r.data[:n]
+	r.data = r.data[n:]
+	return chunk, nil
+}
+
+func (r *mmapReader) close() error {
+	return syscall.Munmap(r.mapping)
+}
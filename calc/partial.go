@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+)
+
+// A partial file holds the sorted stations of a part of the measurements,
+// so that it can be merged with others computed elsewhere. It is made of
+//
+//	the magic PARTIAL_MAGIC and a version byte
+//	the number of stations, as an unsigned varint
+//	for every station, in the order of their names: the length of the
+//	name as an unsigned varint, the name, min and max as varints, acc as
+//	a varint and count as an unsigned varint
+//	the CRC-32C of all the previous bytes, little endian
+const (
+	PARTIAL_MAGIC   = "1BRCPART"
+	PARTIAL_VERSION = 1
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// writePartial encodes stations, which must be sorted by name
+func writePartial(w io.Writer, stations []*WeatherStationInfo) error {
+	sum := crc32.New(castagnoli)
+	bw := bufio.NewWriter(io.MultiWriter(w, sum))
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, PARTIAL_MAGIC...)
+	buf = append(buf, PARTIAL_VERSION)
+	buf = binary.AppendUvarint(buf, uint64(len(stations)))
+	bw.Write(buf)
+
+	for _, wsi := range stations {
+		buf = binary.AppendUvarint(buf[:0], uint64(len(wsi.name)))
+		buf = append(buf, wsi.name...)
+		buf = binary.AppendVarint(buf, int64(wsi.min))
+		buf = binary.AppendVarint(buf, int64(wsi.max))
+		buf = binary.AppendVarint(buf, wsi.acc)
+		buf = binary.AppendUvarint(buf, uint64(wsi.count))
+		bw.Write(buf)
+	}
+
+	err := bw.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(binary.LittleEndian.AppendUint32(nil, sum.Sum32()))
+	return err
+}
+
+// readPartial decodes the stations written by writePartial, checking
+// that they are intact and still sorted by name
+func readPartial(r io.Reader) ([]*WeatherStationInfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < len(PARTIAL_MAGIC)+1+4 || string(data[:len(PARTIAL_MAGIC)]) != PARTIAL_MAGIC {
+		return nil, errors.New("not a partial result")
+	}
+	if v := data[len(PARTIAL_MAGIC)]; v != PARTIAL_VERSION {
+		return nil, fmt.Errorf("unsupported partial result version %d", v)
+	}
+	body, trailer := data[:len(data)-4], data[len(data)-4:]
+	if crc32.Checksum(body, castagnoli) != binary.LittleEndian.Uint32(trailer) {
+		return nil, errors.New("corrupted partial result: checksum mismatch")
+	}
+
+	d := partialDecoder{data: body[len(PARTIAL_MAGIC)+1:]}
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.data)) {
+		// every station takes at least a byte, so this cannot be right
+		d.err = errors.New("too many stations")
+	}
+
+	var stations []*WeatherStationInfo
+	if d.err == nil {
+		stations = make([]*WeatherStationInfo, 0, n)
+	}
+	for i := uint64(0); d.err == nil && i < n; i++ {
+		wsi := &WeatherStationInfo{
+			name:  d.name(),
+			min:   d.int16(),
+			max:   d.int16(),
+			acc:   d.varint(),
+			count: int(d.uvarint()),
+		}
+		if d.err != nil {
+			break
+		}
+
+		switch {
+		case wsi.count <= 0 || wsi.min > wsi.max:
+			d.err = fmt.Errorf("station %q: invalid aggregates", wsi.name)
+		case len(stations) > 0 && stations[len(stations)-1].name >= wsi.name:
+			d.err = fmt.Errorf("station %q: not sorted by name", wsi.name)
+		}
+		stations = append(stations, wsi)
+	}
+	if d.err == nil && len(d.data) > 0 {
+		d.err = fmt.Errorf("%d unexpected bytes after the stations", len(d.data))
+	}
+
+	if d.err != nil {
+		return nil, fmt.Errorf("corrupted partial result: %w", d.err)
+	}
+	return stations, nil
+}
+
+// partialDecoder reads the values of a partial file, stopping at the
+// first error
+type partialDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *partialDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errors.New("truncated or invalid varint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *partialDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err = errors.New("truncated or invalid varint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *partialDecoder) int16() int16 {
+	v := d.varint()
+	if v != int64(int16(v)) {
+		d.err = fmt.Errorf("temperature %d out of range", v)
+	}
+	return int16(v)
+}
+
+func (d *partialDecoder) name() string {
+	l := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	// the names are not limited to MAX_NAME_LENGTH, like the ones calc
+	// parses and writes
+	if l == 0 || l > uint64(len(d.data)) {
+		d.err = fmt.Errorf("invalid station name length %d", l)
+		return ""
+	}
+	name := string(d.data[:l])
+	d.data = d.data[l:]
+	return name
+}
+
+// writePartialFile writes stations to the partial file at path, which is
+// removed if it cannot be written entirely
+func writePartialFile(path string, stations []*WeatherStationInfo) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = writePartial(f, stations)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// mergePartialFiles reads the partial files at paths and merges them
+func mergePartialFiles(paths []string) ([]*WeatherStationInfo, error) {
+	partials := make([][]*WeatherStationInfo, len(paths))
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		partials[i], err = readPartial(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return mergeMatrix(partials), nil
+}
+
+// mergeMain implements "calc merge", which combines the partial files
+// written with -partial-out into the result of all their measurements
+func mergeMain(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	outPath := fs.String("o", "", "path of the result (default the standard output)")
+	partialOut := fs.String("partial-out", "", "also write the merged stations as a partial file, to be merged again")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s merge [ options ] <partial path>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	// Parse stops at the first argument that is not an option, so that
+	// the options may also come after the paths: every path is set aside
+	// and the arguments after it are parsed again
+	var paths []string
+	fs.Parse(args)
+	for fs.NArg() > 0 {
+		paths = append(paths, fs.Arg(0))
+		fs.Parse(fs.Args()[1:])
+	}
+	if len(paths) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	result, err := mergePartialFiles(paths)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *partialOut != "" {
+		err = writePartialFile(*partialOut, result)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	out := os.Stdout
+	if *outPath != "" && *outPath != "-" {
+		out, err = os.Create(*outPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	w := bufio.NewWriter(out)
+	printResult(w, result)
+	err = w.Flush()
+	if out != os.Stdout {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
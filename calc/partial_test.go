@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPartialMerge splits every golden file in shards at line boundaries,
+// computes them separately and checks that merging their partial files
+// gives the result of the whole file
+func TestPartialMerge(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(goldenDir, "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, inPath := range inputs {
+		if strings.HasSuffix(inPath, "-result.txt") {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(inPath), ".txt")
+
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(inPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(filepath.Join(goldenDir, name+"-result.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bounds, err := lineBoundaries(bytes.NewReader(data), 0, int64(len(data)), 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			var parts []string
+			for i := range len(bounds) - 1 {
+				shard := filepath.Join(dir, fmt.Sprintf("shard-%d.txt", i))
+				err = os.WriteFile(shard, data[bounds[i]:bounds[i+1]], 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				result, _, err := process(context.Background(), shard, 2, 64, preadBackend{}, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				part := filepath.Join(dir, fmt.Sprintf("shard-%d.part", i))
+				err = writePartialFile(part, result)
+				if err != nil {
+					t.Fatal(err)
+				}
+				parts = append(parts, part)
+			}
+			if len(parts) == 0 {
+				// an empty file still has its partial file
+				part := filepath.Join(dir, "empty.part")
+				if err := writePartialFile(part, nil); err != nil {
+					t.Fatal(err)
+				}
+				parts = append(parts, part)
+			}
+
+			result, err := mergePartialFiles(parts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			printResult(&out, result)
+			if diff := diffResults(out.String(), string(want)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestPartialRoundTrip(t *testing.T) {
+	stations := []*WeatherStationInfo{
+		{name: "Abha", min: -999, max: 999, acc: -123456789012, count: 1<<31 - 1},
+		{name: "Zürich", min: 5, max: 5, acc: 5, count: 1},
+		{name: strings.Repeat("z", 10*MAX_NAME_LENGTH), min: 0, max: 0, acc: 0, count: 1},
+		{name: strings.Repeat("é", MAX_NAME_LENGTH/2), min: -1, max: 0, acc: -1, count: 2},
+	}
+
+	var buf bytes.Buffer
+	if err := writePartial(&buf, stations); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readPartial(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(stations) {
+		t.Fatalf("got %d stations, want %d", len(got), len(stations))
+	}
+	for i, wsi := range got {
+		if *wsi != *stations[i] {
+			t.Errorf("got %+v, want %+v", *wsi, *stations[i])
+		}
+	}
+}
+
+func TestPartialCorrupted(t *testing.T) {
+	var valid bytes.Buffer
+	err := writePartial(&valid, []*WeatherStationInfo{
+		{name: "a", min: 1, max: 2, acc: 3, count: 2},
+		{name: "b", min: 1, max: 1, acc: 1, count: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite returns the partial file with its body changed by edit and
+	// a valid checksum, to reach the checks behind it
+	rewrite := func(edit func(body []byte) []byte) []byte {
+		body := edit(bytes.Clone(valid.Bytes()[:valid.Len()-4]))
+		return binary.LittleEndian.AppendUint32(body, crc32.Checksum(body, castagnoli))
+	}
+
+	header := len(PARTIAL_MAGIC) + 1
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "not a partial result"},
+		{"magic", append([]byte("1BRCPARX"), valid.Bytes()[len(PARTIAL_MAGIC):]...), "not a partial result"},
+		{"version", rewrite(func(b []byte) []byte { b[header-1] = 2; return b }), "unsupported partial result version 2"},
+		{"flipped bit", func() []byte { b := bytes.Clone(valid.Bytes()); b[header+2] ^= 1; return b }(), "checksum mismatch"},
+		{"truncated", valid.Bytes()[:valid.Len()-5], "checksum mismatch"},
+		{"missing station", rewrite(func(b []byte) []byte { b[header] = 3; return b }), "truncated"},
+		{"extra bytes", rewrite(func(b []byte) []byte { return append(b, 0) }), "1 unexpected bytes"},
+		{"unsorted", rewrite(func(b []byte) []byte { b[header+2] = 'c'; return b }), `"b": not sorted`},
+		{"zero count", rewrite(func(b []byte) []byte { b[header+6] = 0; return b }), `"a": invalid aggregates`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readPartial(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	PROGRESS_INTERVAL     = 250 * time.Millisecond // between the updates of the progress line on a terminal
+	PROGRESS_LOG_INTERVAL = 5 * time.Second        // between the progress log lines otherwise
+)
+
+// progress counts what the workers of process have done so far. They
+// update it once per buffer, and only if they were given one, so that a
+// run without reports does not pay for it
+type progress struct {
+	bytes   atomic.Int64 // read by the workers
+	rows    atomic.Int64 // lines read, counted by their '\n'
+	workers atomic.Int64 // workers still reading their chunk
+}
+
+var newline = []byte{'\n'}
+
+// add counts a buffer read by a worker
+func (p *progress) add(chunk []byte) {
+	p.bytes.Add(int64(len(chunk)))
+	p.rows.Add(int64(bytes.Count(chunk, newline)))
+}
+
+// report writes the progress of a run over total bytes to out until the
+// returned function is called, which writes it a last time. On a terminal
+// it is a single line updated in place, otherwise a log line every
+// PROGRESS_LOG_INTERVAL
+func (p *progress) report(out *os.File, total int64) (stop func()) {
+	terminal := isTerminal(out)
+	interval := PROGRESS_LOG_INTERVAL
+	if terminal {
+		interval = PROGRESS_INTERVAL
+	}
+	logger := log.New(out, "", log.LstdFlags)
+
+	start := time.Now()
+	print := func() {
+		line := p.format(total, time.Since(start))
+		if terminal {
+			// \x1b[K clears what is left of a longer previous line
+			fmt.Fprintf(out, "\r%s\x1b[K", line)
+		} else {
+			logger.Println(line)
+		}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				print()
+			case <-done:
+				print()
+				if terminal {
+					fmt.Fprintln(out)
+				}
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// format describes the progress after elapsed, like
+// "1.2 GB / 13.0 GB (9.2%), 850.3 MB/s, 61.2M rows/s, ETA 14s, 160 workers"
+func (p *progress) format(total int64, elapsed time.Duration) string {
+	read, rows, workers := p.bytes.Load(), p.rows.Load(), p.workers.Load()
+
+	percent := 100.0
+	if total > 0 {
+		percent = 100 * float64(read) / float64(total)
+	}
+	s := fmt.Sprintf("%s / %s (%.1f%%)", formatBytes(read), formatBytes(total), percent)
+
+	seconds := elapsed.Seconds()
+	if seconds <= 0 || read == 0 {
+		return s + fmt.Sprintf(", %d workers", workers)
+	}
+	rate := float64(read) / seconds
+	eta := time.Duration(float64(max(total-read, 0)) / rate * float64(time.Second))
+	return s + fmt.Sprintf(", %.1f MB/s, %s rows/s, ETA %v, %d workers", rate/1e6, formatCount(float64(rows)/seconds), eta.Round(time.Second), workers)
+}
+
+// formatBytes prints n in decimal units, like "13.0 GB"
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1f GB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1f MB", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1f kB", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// formatCount prints n with a metric suffix, like "61.2M"
+func formatCount(n float64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1fG", n/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1fM", n/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1fk", n/1e3)
+	default:
+		return fmt.Sprintf("%.0f", n)
+	}
+}
+
+// dataSize is the number of bytes of the file at path that the workers
+// read: all of them but the byte order mark
+func dataSize(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	format, err := detectFormat(f, info.Size())
+	if err != nil {
+		return 0, err
+	}
+	return info.Size() - format.start(), nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProgressCounts(t *testing.T) {
+	for _, name := range []string{"unique-10000", "crlf-bom-no-trailing-newline", "empty"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(goldenDir, name+".txt")
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var p progress
+			_, format, err := process(context.Background(), path, 3, 64, preadBackend{}, &p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the workers read everything after the byte order mark
+			data = data[format.start():]
+			if got := p.bytes.Load(); got != int64(len(data)) {
+				t.Errorf("got %d bytes, want %d", got, len(data))
+			}
+			if got, want := p.rows.Load(), int64(bytes.Count(data, newline)); got != want {
+				t.Errorf("got %d rows, want %d", got, want)
+			}
+			if got := p.workers.Load(); got != 0 {
+				t.Errorf("%d workers still active", got)
+			}
+			if total, err := dataSize(path); err != nil || total != int64(len(data)) {
+				t.Errorf("got a total of %d bytes and %v, want %d", total, err, len(data))
+			}
+		})
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	// a character device, but not a terminal
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer null.Close()
+	if isTerminal(null) {
+		t.Errorf("%s is a terminal", os.DevNull)
+	}
+
+	f, err := os.Open(filepath.Join(goldenDir, "empty.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if isTerminal(f) {
+		t.Error("a file is a terminal")
+	}
+}
+
+func TestProgressFormat(t *testing.T) {
+	var p progress
+	if got, want := p.format(13e9, 0), "0 B / 13.0 GB (0.0%), 0 workers"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	p.bytes.Store(1.3e9)
+	p.rows.Store(100e6)
+	p.workers.Store(160)
+	want := "1.3 GB / 13.0 GB (10.0%), 650.0 MB/s, 50.0M rows/s, ETA 18s, 160 workers"
+	if got := p.format(13e9, 2*time.Second); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MAX_NAME_LENGTH is the longest station name allowed by the challenge,
+// in bytes, and MAX_LINE_LENGTH the longest line with its temperature
+const (
+	MAX_NAME_LENGTH = 100
+	MAX_LINE_LENGTH = MAX_NAME_LENGTH + len(";-99.9")
+)
+
+// the default limits of calc serve
+const (
+	MAX_BODY_SIZE = 1 << 30   // of a POST /aggregate, compressed or not
+	MAX_STATIONS  = 1_000_000 // distinct stations of a POST /aggregate
+	MAX_JOBS      = 1000      // jobs kept at once, running or finished
+	JOB_TTL       = time.Hour // how long a finished job is kept
+)
+
+// serveMain implements "calc serve", which exposes the engine over HTTP:
+//
+//	POST /aggregate         the result of the measurements in the body
+//	POST /jobs              start a job on a file under the root directory
+//	GET  /jobs/{id}         the status and progress of a job
+//	GET  /jobs/{id}/result  the result of a finished job, or why it failed
+//	DELETE /jobs/{id}       cancel a job if it is still running, and forget it
+//
+// The results are in the format of the challenge, or in JSON with
+// ?format=json or an Accept: application/json header
+func serveMain(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	root := fs.String("root", ".", "directory holding the files the jobs can read")
+	maxBody := fs.String("max-body", "1GiB", "largest body of a POST /aggregate")
+	maxStations := fs.Int("max-stations", MAX_STATIONS, "most distinct stations of a POST /aggregate")
+	maxJobs := fs.Int("max-jobs", MAX_JOBS, "most jobs kept at once, running or finished")
+	jobTTL := fs.Duration("job-ttl", JOB_TTL, "how long a finished job is kept")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s serve [ options ]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	s := newServer(*root)
+	var err error
+	s.maxBody, err = parseSize(*maxBody)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	s.maxStations, s.maxJobs, s.jobTTL = *maxStations, *maxJobs, *jobTTL
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Serving on %s, with the jobs reading from %s\n", *addr, *root)
+	log.Fatalln(server.ListenAndServe())
+}
+
+type server struct {
+	root        string
+	maxBody     int64
+	maxStations int
+	maxJobs     int
+	jobTTL      time.Duration
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	nextID int
+}
+
+// job is the computation of a file in the background
+type job struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Started time.Time `json:"started"`
+
+	progress progress
+	cancel   context.CancelCauseFunc
+
+	mu       sync.Mutex
+	finished time.Time
+	result   []*WeatherStationInfo
+	err      error
+}
+
+// jobStatus is the JSON description of a job
+type jobStatus struct {
+	*job
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+	Read     int64   `json:"read"`
+	Progress float64 `json:"progress"`
+	Elapsed  string  `json:"elapsed"`
+}
+
+// stationJSON is a station of a JSON result. The values are numbers
+// written with exactly one decimal digit, like in the text result
+type stationJSON struct {
+	Name  string      `json:"name"`
+	Min   json.Number `json:"min"`
+	Mean  json.Number `json:"mean"`
+	Max   json.Number `json:"max"`
+	Count int         `json:"count"`
+}
+
+func newServer(root string) *server {
+	return &server{
+		root:        root,
+		maxBody:     MAX_BODY_SIZE,
+		maxStations: MAX_STATIONS,
+		maxJobs:     MAX_JOBS,
+		jobTTL:      JOB_TTL,
+		jobs:        make(map[string]*job),
+	}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /aggregate", s.aggregate)
+	mux.HandleFunc("POST /jobs", s.startJob)
+	mux.HandleFunc("GET /jobs/{id}", s.jobStatus)
+	mux.HandleFunc("GET /jobs/{id}/result", s.jobResult)
+	mux.HandleFunc("DELETE /jobs/{id}", s.deleteJob)
+	return mux
+}
+
+// aggregate computes the measurements of the request body as it is
+// streamed, which may be compressed with gzip
+func (s *server) aggregate(w http.ResponseWriter, r *http.Request) {
+	body := bufio.NewReaderSize(http.MaxBytesReader(w, r.Body, s.maxBody), BUFFER_SIZE)
+
+	// a gzip stream is recognised by its magic number, so that
+	// compressed files can be sent as they are
+	var in io.Reader = body
+	if magic, _ := body.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	a := NewAggregator()
+	err := aggregateLines(in, a, s.maxStations)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || errors.Is(err, errTooManyStations) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	serveResult(w, r, a.Results())
+}
+
+var errTooManyStations = errors.New("too many stations")
+
+// aggregateLines adds the lines read from in to a, checking that every
+// one of them is a measurement allowed by the challenge and that there
+// are at most maxStations stations
+func aggregateLines(in io.Reader, a *Aggregator, maxStations int) error {
+	br := bufio.NewReaderSize(in, BUFFER_SIZE)
+
+	for n := 1; ; n++ {
+		line, err := br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return fmt.Errorf("line %d: longer than %d bytes", n, MAX_LINE_LENGTH)
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if n == 1 {
+			line = bytes.TrimPrefix(line, utf8BOM)
+		}
+		text := bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+		if len(text) > 0 {
+			if checkErr := checkLine(text); checkErr != nil {
+				return fmt.Errorf("line %d: %w", n, checkErr)
+			}
+			a.AddLine(text)
+			if a.Len() > maxStations {
+				return fmt.Errorf("line %d: %w, the limit is %d", n, errTooManyStations, maxStations)
+			}
+		}
+
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// checkLine reports why line, without its end of line, is not a valid
+// measurement: parseLine assumes that it is
+func checkLine(line []byte) error {
+	if len(line) > MAX_LINE_LENGTH {
+		return fmt.Errorf("longer than %d bytes", MAX_LINE_LENGTH)
+	}
+
+	i := bytes.IndexByte(line, ';')
+	if i < 1 {
+		return fmt.Errorf("missing station name or ';' in %q", line)
+	}
+	if i > MAX_NAME_LENGTH {
+		return fmt.Errorf("station name of %d bytes", i)
+	}
+
+	temp := bytes.TrimPrefix(line[i+1:], []byte{'-'})
+	valid := len(temp) == 3 || len(temp) == 4 && temp[0] != '0'
+	if valid {
+		for j, c := range temp {
+			if j == len(temp)-2 {
+				valid = valid && c == '.'
+			} else {
+				valid = valid && c >= '0' && c <= '9'
+			}
+		}
+	}
+	if !valid {
+		return fmt.Errorf("invalid temperature %q", line[i+1:])
+	}
+	return nil
+}
+
+// startJob starts the computation of the file at the path of a
+// {"path": "..."} body, relative to the root directory
+func (s *server) startJob(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path string `json:"path"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !filepath.IsLocal(request.Path) {
+		http.Error(w, "the path must be relative to the root directory, without \"..\"", http.StatusBadRequest)
+		return
+	}
+	path, err := s.resolve(request.Path)
+	if errors.Is(err, errOutsideRoot) {
+		http.Error(w, request.Path+": "+err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		http.Error(w, request.Path+" is not a file", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	if !s.evict(time.Now()) {
+		s.mu.Unlock()
+		http.Error(w, fmt.Sprintf("%d jobs are already running", len(s.jobs)), http.StatusServiceUnavailable)
+		return
+	}
+	s.nextID++
+	ctx, cancel := context.WithCancelCause(context.Background())
+	j := &job{
+		ID:      strconv.Itoa(s.nextID),
+		Path:    request.Path,
+		Size:    info.Size(),
+		Started: time.Now(),
+		cancel:  cancel,
+	}
+	s.jobs[j.ID] = j
+	s.mu.Unlock()
+
+	go func() {
+		result, _, err := process(ctx, path, 0, BUFFER_SIZE, preadBackend{}, &j.progress)
+		cancel(nil)
+
+		j.mu.Lock()
+		j.result, j.err = result, err
+		j.finished = time.Now()
+		j.mu.Unlock()
+	}()
+
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(j.status())
+}
+
+var errOutsideRoot = errors.New("outside of the root directory")
+
+// resolve returns the path of name under the root directory with its
+// symbolic links resolved, or errOutsideRoot if one of them leads out of
+// it: the path alone only says where the links are
+func (s *server) resolve(name string) (string, error) {
+	return resolveUnder(s.root, filepath.Join(s.root, name))
+}
+
+// resolveUnder returns path with its symbolic links resolved, or
+// errOutsideRoot if it is not under root once the links of both are
+func resolveUnder(root string, path string) (string, error) {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", errOutsideRoot
+	}
+	return path, nil
+}
+
+// evict forgets the jobs finished for longer than the TTL and, if there
+// are still too many jobs, the oldest finished ones. It reports whether
+// there is room for another job. s.mu must be held
+func (s *server) evict(now time.Time) bool {
+	var finished []*job
+	for id, j := range s.jobs {
+		j.mu.Lock()
+		end := j.finished
+		j.mu.Unlock()
+
+		switch {
+		case end.IsZero():
+		case now.Sub(end) >= s.jobTTL:
+			delete(s.jobs, id)
+		default:
+			finished = append(finished, j)
+		}
+	}
+
+	// the finished jobs do not change anymore
+	slices.SortFunc(finished, func(a, b *job) int { return a.finished.Compare(b.finished) })
+	for _, j := range finished {
+		if len(s.jobs) < s.maxJobs {
+			break
+		}
+		delete(s.jobs, j.ID)
+	}
+	return len(s.jobs) < s.maxJobs
+}
+
+func (s *server) job(w http.ResponseWriter, r *http.Request) *job {
+	s.mu.Lock()
+	j := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+
+	if j == nil {
+		http.Error(w, "no job "+r.PathValue("id"), http.StatusNotFound)
+	}
+	return j
+}
+
+func (s *server) jobStatus(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(j.status())
+}
+
+var errJobCancelled = errors.New("cancelled")
+
+func (s *server) deleteJob(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+
+	j.cancel(errJobCancelled)
+	s.mu.Lock()
+	delete(s.jobs, j.ID)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) jobResult(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	result, err, done := j.result, j.err, !j.finished.IsZero()
+	j.mu.Unlock()
+
+	if !done {
+		http.Error(w, "job "+j.ID+" is still running", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "job "+j.ID+" failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	serveResult(w, r, result)
+}
+
+func (j *job) status() jobStatus {
+	j.mu.Lock()
+	finished, err := j.finished, j.err
+	j.mu.Unlock()
+
+	st := jobStatus{
+		job:     j,
+		Status:  "running",
+		Read:    j.progress.bytes.Load(),
+		Elapsed: time.Since(j.Started).String(),
+	}
+	switch {
+	case err != nil:
+		st.Status = "failed"
+		st.Error = err.Error()
+		st.Elapsed = finished.Sub(j.Started).String()
+	case !finished.IsZero():
+		st.Status = "done"
+		st.Read = j.Size
+		st.Elapsed = finished.Sub(j.Started).String()
+	}
+
+	st.Progress = 1
+	if j.Size > 0 {
+		st.Progress = float64(st.Read) / float64(j.Size)
+	}
+	return st
+}
+
+// serveResult writes result in JSON if the request asks for it, with
+// ?format=json or by accepting application/json, and in the format of
+// the challenge otherwise
+func serveResult(w http.ResponseWriter, r *http.Request, result []*WeatherStationInfo) {
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "application/json") {
+		format = "json"
+	}
+
+	if format != "json" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		printResult(w, result)
+		return
+	}
+
+	stations := make([]stationJSON, len(result))
+	for i, x := range result {
+		stations[i] = stationJSON{
+			Name:  x.name,
+			Min:   json.Number(formatTenths(int64(x.min))),
+			Mean:  json.Number(formatTenths(roundMean(x.acc, x.count))),
+			Max:   json.Number(formatTenths(int64(x.max))),
+			Count: x.count,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"stations": stations})
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeAggregate(t *testing.T) {
+	ts := httptest.NewServer(newServer(goldenDir).handler())
+	defer ts.Close()
+
+	inputs, err := filepath.Glob(filepath.Join(goldenDir, "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, inPath := range inputs {
+		if strings.HasSuffix(inPath, "-result.txt") {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(inPath), ".txt")
+
+		data, err := os.ReadFile(inPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile(filepath.Join(goldenDir, name+"-result.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		gz.Write(data)
+		gz.Close()
+
+		for kind, body := range map[string][]byte{"plain": data, "gzip": compressed.Bytes()} {
+			t.Run(name+"/"+kind, func(t *testing.T) {
+				status, got := post(t, ts.URL+"/aggregate", body)
+				if status != http.StatusOK {
+					t.Fatalf("got status %d: %s", status, got)
+				}
+				if diff := diffResults(got, string(want)); diff != "" {
+					t.Error(diff)
+				}
+			})
+		}
+	}
+}
+
+func TestServeAggregateJSON(t *testing.T) {
+	ts := httptest.NewServer(newServer(goldenDir).handler())
+	defer ts.Close()
+
+	status, got := post(t, ts.URL+"/aggregate?format=json", []byte("b;-0.1\na;10.0\nb;-0.2\n"))
+	if status != http.StatusOK {
+		t.Fatalf("got status %d: %s", status, got)
+	}
+
+	want := `{"stations":[{"name":"a","min":10.0,"mean":10.0,"max":10.0,"count":1},{"name":"b","min":-0.2,"mean":-0.1,"max":-0.1,"count":2}]}`
+	if strings.TrimSpace(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestServeAggregateMalformed(t *testing.T) {
+	ts := httptest.NewServer(newServer(goldenDir).handler())
+	defer ts.Close()
+
+	for in, want := range map[string]string{
+		"a;1.0\nb;2.0\nc 3.0\n":              "line 3: missing station name or ';'",
+		"a;1.0\n;2.0\n":                      "line 2: missing station name or ';'",
+		"a;1\n":                              `line 1: invalid temperature "1"`,
+		"a;1.00\n":                           `line 1: invalid temperature "1.00"`,
+		"a;01.0\n":                           `line 1: invalid temperature "01.0"`,
+		"a;--1.0\n":                          `line 1: invalid temperature "--1.0"`,
+		strings.Repeat("x", 101) + ";1.0\n":  "line 1: station name of 101 bytes",
+		"a;1.0\n" + strings.Repeat("x", 200): "line 2: longer than 106 bytes",
+		"\x1f\x8bnot really gzip":            "gzip",
+	} {
+		status, got := post(t, ts.URL+"/aggregate", []byte(in))
+		if status != http.StatusBadRequest || !strings.Contains(got, want) {
+			t.Errorf("%q: got status %d and %q, want %q", in, status, got, want)
+		}
+	}
+}
+
+func TestServeJobs(t *testing.T) {
+	ts := httptest.NewServer(newServer(goldenDir).handler())
+	defer ts.Close()
+
+	status, got := post(t, ts.URL+"/jobs", []byte(`{"path": "unique-10000.txt"}`))
+	if status != http.StatusAccepted {
+		t.Fatalf("got status %d: %s", status, got)
+	}
+	var started struct{ ID string }
+	err := json.Unmarshal([]byte(got), &started)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitJob(t, ts.URL, started.ID)
+
+	want, err := os.ReadFile(filepath.Join(goldenDir, "unique-10000-result.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, got = get(t, ts.URL+"/jobs/"+started.ID+"/result")
+	if status != http.StatusOK {
+		t.Fatalf("got status %d: %s", status, got)
+	}
+	if diff := diffResults(got, string(want)); diff != "" {
+		t.Error(diff)
+	}
+
+	for body, want := range map[string]int{
+		`{"path": "../README.md"}`: http.StatusBadRequest,
+		`{"path": "/etc/passwd"}`:  http.StatusBadRequest,
+		`{"path": "missing.txt"}`:  http.StatusNotFound,
+		`{"path": "."}`:            http.StatusBadRequest,
+		`not json`:                 http.StatusBadRequest,
+	} {
+		if status, got := post(t, ts.URL+"/jobs", []byte(body)); status != want {
+			t.Errorf("%s: got status %d, want %d: %s", body, status, want, got)
+		}
+	}
+	if status, _ := get(t, ts.URL+"/jobs/1000"); status != http.StatusNotFound {
+		t.Errorf("unknown job: got status %d", status)
+	}
+}
+
+func TestServeJobsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	err := os.Mkdir(root, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("a;1.0\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside, err := filepath.Abs(filepath.Join(goldenDir, "unique-10000.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(outside, filepath.Join(root, "outside.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink("..", filepath.Join(root, "parent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(root, "inside.txt"), []byte("a;1.0\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink("inside.txt", filepath.Join(root, "link.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(newServer(root).handler())
+	defer ts.Close()
+
+	for body, want := range map[string]int{
+		`{"path": "outside.txt"}`:            http.StatusBadRequest,
+		`{"path": "parent/secret.txt"}`:      http.StatusBadRequest,
+		`{"path": "parent/root/inside.txt"}`: http.StatusAccepted,
+		`{"path": "link.txt"}`:               http.StatusAccepted,
+	} {
+		if status, got := post(t, ts.URL+"/jobs", []byte(body)); status != want {
+			t.Errorf("%s: got status %d, want %d: %s", body, status, want, got)
+		}
+	}
+}
+
+// startJob starts a job on path and returns its ID
+func startJob(t *testing.T, url string, path string) string {
+	status, got := post(t, url+"/jobs", []byte(`{"path": "`+path+`"}`))
+	if status != http.StatusAccepted {
+		t.Fatalf("got status %d: %s", status, got)
+	}
+	var started struct{ ID string }
+	err := json.Unmarshal([]byte(got), &started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return started.ID
+}
+
+// waitJob waits for the job id to be done
+func waitJob(t *testing.T, url string, id string) {
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		var st struct {
+			Status   string
+			Progress float64
+		}
+		_, body := get(t, url+"/jobs/"+id)
+		err := json.Unmarshal([]byte(body), &st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Status == "done" {
+			if st.Progress != 1 {
+				t.Errorf("got progress %g for a finished job", st.Progress)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job still %s after 10s", st.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServeJobsEviction(t *testing.T) {
+	s := newServer(goldenDir)
+	s.maxJobs = 2
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	// the oldest finished job makes room for a new one
+	first := startJob(t, ts.URL, "unique-10000.txt")
+	waitJob(t, ts.URL, first)
+	second := startJob(t, ts.URL, "unique-10000.txt")
+	waitJob(t, ts.URL, second)
+	third := startJob(t, ts.URL, "unique-10000.txt")
+	if status, _ := get(t, ts.URL+"/jobs/"+first); status != http.StatusNotFound {
+		t.Errorf("oldest job: got status %d", status)
+	}
+	if status, _ := get(t, ts.URL+"/jobs/"+second); status != http.StatusOK {
+		t.Errorf("newer job: got status %d", status)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+"/jobs/"+third, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, got := readResponse(t, resp); status != http.StatusNoContent {
+		t.Errorf("delete: got status %d: %s", status, got)
+	}
+	if status, _ := get(t, ts.URL+"/jobs/"+third); status != http.StatusNotFound {
+		t.Errorf("deleted job: got status %d", status)
+	}
+
+	// and the finished jobs expire
+	s.mu.Lock()
+	s.evict(time.Now().Add(JOB_TTL))
+	s.mu.Unlock()
+	if status, _ := get(t, ts.URL+"/jobs/"+second); status != http.StatusNotFound {
+		t.Errorf("expired job: got status %d", status)
+	}
+}
+
+func TestServeAggregateLimits(t *testing.T) {
+	s := newServer(goldenDir)
+	s.maxBody = 64
+	s.maxStations = 2
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	for in, want := range map[string]string{
+		strings.Repeat("a;1.0\n", 20): "too large",
+		"a;1.0\nb;2.0\nc;3.0\n":       "line 3: too many stations",
+	} {
+		status, got := post(t, ts.URL+"/aggregate", []byte(in))
+		if status != http.StatusRequestEntityTooLarge || !strings.Contains(got, want) {
+			t.Errorf("%q: got status %d and %q, want %q", in, status, got, want)
+		}
+	}
+	if status, got := post(t, ts.URL+"/aggregate", []byte("a;1.0\nb;2.0\na;3.0\n")); status != http.StatusOK {
+		t.Errorf("got status %d: %s", status, got)
+	}
+}
+
+func post(t *testing.T, url string, body []byte) (int, string) {
+	resp, err := http.Post(url, "text/plain", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readResponse(t, resp)
+}
+
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readResponse(t, resp)
+}
+
+func readResponse(t *testing.T, resp *http.Response) (int, string) {
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
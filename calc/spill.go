@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// STATION_MEMORY is the estimated size of a station in the table of a
+	// worker: the map entry, the WeatherStationInfo and a name of about
+	// 30 bytes
+	STATION_MEMORY = 128
+	// RUN_BLOCK_STATIONS is the number of stations of every block of a
+	// spilled run, which is what is kept in memory of a run while merging
+	RUN_BLOCK_STATIONS = 4096
+	// MAX_MERGE_RUNS is the number of runs opened at once by a merge: if
+	// there are more, they are merged in groups first
+	MAX_MERGE_RUNS = 128
+)
+
+// spiller writes the tables of the workers of process to temporary files
+// once they hold more stations than their share of a memory budget. Every
+// file is a run, sorted by name, made of partial results of at most
+// RUN_BLOCK_STATIONS stations each preceded by its length as an unsigned
+// varint
+type spiller struct {
+	dir         string
+	budget      int64
+	maxStations int // per worker, set by setWorkers
+
+	mu     sync.Mutex
+	runs   []string
+	spills atomic.Int64
+}
+
+func newSpiller(dir string, budget int64) *spiller {
+	return &spiller{dir: dir, budget: budget}
+}
+
+// setWorkers shares the budget among the given number of workers
+func (sp *spiller) setWorkers(workers int) {
+	sp.maxStations = int(sp.budget / int64(workers) / STATION_MEMORY)
+}
+
+// spill writes the stations of m to a new run, and empties m
+func (sp *spiller) spill(m map[uint64]*WeatherStationInfo) error {
+	path, err := sp.writeRun(func(rw *runWriter) error {
+		for _, wsi := range sortedValues(m) {
+			err := rw.add(wsi)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sp.mu.Lock()
+	sp.runs = append(sp.runs, path)
+	sp.mu.Unlock()
+	sp.spills.Add(1)
+
+	clear(m)
+	return nil
+}
+
+// writeRun creates a run in the directory of sp, filled by write
+func (sp *spiller) writeRun(write func(rw *runWriter) error) (string, error) {
+	f, err := os.CreateTemp(sp.dir, "run-*")
+	if err != nil {
+		return "", err
+	}
+
+	rw := &runWriter{w: bufio.NewWriter(f)}
+	err = write(rw)
+	if err == nil {
+		err = rw.flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// merge merges partial, sorted by name, with the runs written so far,
+// calling emit with every station in order. The runs are removed once
+// they have been read
+func (sp *spiller) merge(ctx context.Context, partial []*WeatherStationInfo, emit func(*WeatherStationInfo) error) error {
+	runs := sp.runs
+	sp.runs = nil
+
+	// the groups of runs are merged in a new run each, until the last
+	// merge can open them all
+	for len(runs) > MAX_MERGE_RUNS {
+		group := runs[:MAX_MERGE_RUNS]
+		path, err := sp.writeRun(func(rw *runWriter) error {
+			return mergeRuns(ctx, group, nil, rw.add)
+		})
+		if err != nil {
+			return err
+		}
+		runs = append(runs[MAX_MERGE_RUNS:], path)
+	}
+
+	return mergeRuns(ctx, runs, partial, emit)
+}
+
+// runWriter writes the blocks of a run
+type runWriter struct {
+	w     *bufio.Writer
+	block []*WeatherStationInfo
+	buf   bytes.Buffer
+}
+
+func (rw *runWriter) add(wsi *WeatherStationInfo) error {
+	rw.block = append(rw.block, wsi)
+	if len(rw.block) < RUN_BLOCK_STATIONS {
+		return nil
+	}
+	return rw.writeBlock()
+}
+
+func (rw *runWriter) writeBlock() error {
+	rw.buf.Reset()
+	err := writePartial(&rw.buf, rw.block)
+	if err != nil {
+		return err
+	}
+	rw.block = rw.block[:0]
+
+	rw.w.Write(binary.AppendUvarint(nil, uint64(rw.buf.Len())))
+	_, err = rw.w.Write(rw.buf.Bytes())
+	return err
+}
+
+func (rw *runWriter) flush() error {
+	if len(rw.block) > 0 {
+		err := rw.writeBlock()
+		if err != nil {
+			return err
+		}
+	}
+	return rw.w.Flush()
+}
+
+// stationSource gives stations sorted by name, one at a time, and io.EOF
+// after the last one
+type stationSource interface {
+	next() (*WeatherStationInfo, error)
+}
+
+// sliceSource gives the stations of a slice
+type sliceSource []*WeatherStationInfo
+
+func (s *sliceSource) next() (*WeatherStationInfo, error) {
+	if len(*s) == 0 {
+		return nil, io.EOF
+	}
+	wsi := (*s)[0]
+	*s = (*s)[1:]
+	return wsi, nil
+}
+
+// runReader gives the stations of a run, reading a block at a time
+type runReader struct {
+	path  string
+	r     *bufio.Reader
+	block sliceSource
+	last  string
+}
+
+func (rr *runReader) next() (*WeatherStationInfo, error) {
+	for len(rr.block) == 0 {
+		size, err := binary.ReadUvarint(rr.r)
+		if err != nil {
+			// the end of the file is only expected before a block
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("%s: %w", rr.path, err)
+		}
+
+		block, err := readPartial(io.LimitReader(rr.r, int64(size)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rr.path, err)
+		}
+		if len(block) > 0 && block[0].name <= rr.last {
+			return nil, fmt.Errorf("%s: station %q: not sorted by name", rr.path, block[0].name)
+		}
+		rr.block = block
+	}
+
+	wsi, _ := rr.block.next()
+	rr.last = wsi.name
+	return wsi, nil
+}
+
+// mergeRuns merges the runs at paths with partial, which is sorted by
+// name, calling emit with every station in order: the stations of the
+// same name are merged into one. The runs are removed once read
+func mergeRuns(ctx context.Context, paths []string, partial []*WeatherStationInfo, emit func(*WeatherStationInfo) error) error {
+	sources := make([]stationSource, 0, len(paths)+1)
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(path)
+		defer f.Close()
+		sources = append(sources, &runReader{path: path, r: bufio.NewReader(f)})
+	}
+	sources = append(sources, (*sliceSource)(&partial))
+
+	h := make(sourceHeap, 0, len(sources))
+	for _, src := range sources {
+		wsi, err := src.next()
+		if err == io.EOF {
+			continue
+		} else if err != nil {
+			return err
+		}
+		h = append(h, sourceHead{wsi, src})
+	}
+	heap.Init(&h)
+
+	// advance replaces the first station of the heap with the next one of
+	// its source
+	advance := func() error {
+		wsi, err := h[0].src.next()
+		if err == io.EOF {
+			heap.Pop(&h)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		h[0].wsi = wsi
+		heap.Fix(&h, 0)
+		return nil
+	}
+
+	for n := 0; len(h) > 0; n++ {
+		if n%RUN_BLOCK_STATIONS == 0 && ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+
+		wsi := h[0].wsi
+		err := advance()
+		for err == nil && len(h) > 0 && h[0].wsi.name == wsi.name {
+			wsi.merge(h[0].wsi)
+			err = advance()
+		}
+		if err != nil {
+			return err
+		}
+
+		err = emit(wsi)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceHead is the next station of a source being merged
+type sourceHead struct {
+	wsi *WeatherStationInfo
+	src stationSource
+}
+
+// sourceHeap orders the sources being merged by their next station
+type sourceHeap []sourceHead
+
+func (h sourceHeap) Len() int           { return len(h) }
+func (h sourceHeap) Less(i, j int) bool { return h[i].wsi.name < h[j].wsi.name }
+func (h sourceHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *sourceHeap) Push(x any)        { *h = append(*h, x.(sourceHead)) }
+func (h *sourceHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// processSpilling is process with the tables of the workers spilled by
+// sp when they grow past its budget. The result is not returned but given
+// to emit a station at a time, in order
+func processSpilling(ctx context.Context, inFilePath string, workers int, bufferSize int, b backend, p *progress, sp *spiller, emit func(*WeatherStationInfo) error) (inputFormat, error) {
+	partials, format, err := processChunks(ctx, inFilePath, workers, bufferSize, b, p, sp)
+	if err != nil {
+		return format, err
+	}
+
+	// what is left in the tables fits in the budget, by construction
+	return format, sp.merge(ctx, mergeMatrix(partials), emit)
+}
+
+// printResultSpilling is printResult for processSpilling, which spills
+// to a temporary directory under dir with the given budget in bytes
+func printResultSpilling(ctx context.Context, out io.Writer, inFilePath string, b backend, p *progress, dir string, budget int64) (inputFormat, error) {
+	dir, err := os.MkdirTemp(dir, "calc-spill-")
+	if err != nil {
+		return inputFormat{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	fmt.Fprint(out, "{\n")
+	first := true
+	format, err := processSpilling(ctx, inFilePath, 0, BUFFER_SIZE, b, p, newSpiller(dir, budget), func(wsi *WeatherStationInfo) error {
+		printStation(out, wsi, first)
+		first = false
+		return nil
+	})
+	if err != nil {
+		return format, err
+	}
+	fmt.Fprint(out, "\n}\n")
+	return format, nil
+}
+
+// sizeRegexp and sizeUnits are the sizes with a unit that create takes,
+// like 5GB or 512MiB: testdata/sizes.tsv holds the ones both must read
+// the same way
+var sizeRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([KMGT]i?)?B$`)
+
+var sizeUnits = map[string]float64{
+	"":  1,
+	"K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12,
+	"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40,
+}
+
+// parseSize parses a number of bytes, with the same units as create, like
+// 512MiB or 1.5TB, or without any
+func parseSize(s string) (int64, error) {
+	var size float64
+	if m := sizeRegexp.FindStringSubmatch(s); m != nil {
+		n, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid size %q", s)
+		}
+		size = n * sizeUnits[m[2]]
+	} else {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid size %q", s)
+		}
+		size = float64(n)
+	}
+
+	if size < 1 || size > 1<<62 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(size), nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestSpilling forces the workers to spill their tables with tiny budgets
+// and checks that merging the runs gives the result of the golden files
+func TestSpilling(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(goldenDir, "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, inPath := range inputs {
+		if strings.HasSuffix(inPath, "-result.txt") {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(inPath), ".txt")
+
+		want, err := os.ReadFile(filepath.Join(goldenDir, name+"-result.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a budget of a byte spills after every buffer, and the unique
+		// stations then need more runs than a merge opens at once
+		for _, budget := range []int64{1, 3 * 50 * STATION_MEMORY} {
+			t.Run(fmt.Sprintf("%s/budget=%d", name, budget), func(t *testing.T) {
+				dir := t.TempDir()
+				sp := newSpiller(dir, budget)
+
+				var result []*WeatherStationInfo
+				_, err := processSpilling(context.Background(), inPath, 3, 64, preadBackend{}, nil, sp, func(wsi *WeatherStationInfo) error {
+					result = append(result, wsi)
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var out bytes.Buffer
+				printResult(&out, result)
+				if diff := diffResults(out.String(), string(want)); diff != "" {
+					t.Error(diff)
+				}
+
+				if name == "unique-10000" && sp.spills.Load() <= MAX_MERGE_RUNS {
+					t.Errorf("only %d spills", sp.spills.Load())
+				}
+				if left, _ := os.ReadDir(dir); len(left) > 0 {
+					t.Errorf("%d runs left behind", len(left))
+				}
+			})
+		}
+	}
+}
+
+func TestRunSpilling(t *testing.T) {
+	in := filepath.Join(goldenDir, "unique-10000.txt")
+	want, err := os.ReadFile(filepath.Join(goldenDir, "unique-10000-result.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "result.txt")
+	err = run(context.Background(), []string{"-memory", "64KiB", "-spill-dir", dir, in, out})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := diffResults(string(got), string(want)); diff != "" {
+		t.Error(diff)
+	}
+	if left, _ := os.ReadDir(dir); len(left) != 1 {
+		t.Errorf("got %d files in the spill directory, want only the result", len(left))
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{
+		"1":      1,
+		"4096":   4096,
+		"512MiB": 512 << 20,
+	} {
+		got, err := parseSize(in)
+		if err != nil || got != want {
+			t.Errorf("%q: got %d, %v, want %d", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "0", "-1", "0.1B", "9000000000GiB"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+// TestParseSizeShared checks parseSize with the sizes that create reads
+// too, so that both take the same units
+func TestParseSizeShared(t *testing.T) {
+	data, err := os.ReadFile("../testdata/sizes.tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		in, want, _ := strings.Cut(line, "\t")
+
+		got, err := parseSize(in)
+		if want == "invalid" {
+			if err == nil {
+				t.Errorf("%q: got %d, expected an error", in, got)
+			}
+		} else if err != nil || strconv.FormatInt(got, 10) != want {
+			t.Errorf("%q: got %d, %v, want %s", in, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import "os"
+
+// isTerminal reports false where there is no termios, so that the
+// progress is logged instead of updated in place
+func isTerminal(f *os.File) bool {
+	return false
+}
@@ -0,0 +1,403 @@
+//go:build linux && (amd64 || arm64 || riscv64 || loong64)
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// The io_uring interface of <linux/io_uring.h>, used through raw system
+// calls. These architectures share the generic system call numbers
+const (
+	SYS_IO_URING_SETUP    = 425
+	SYS_IO_URING_ENTER    = 426
+	SYS_IO_URING_REGISTER = 427
+
+	IORING_OFF_SQ_RING = 0
+	IORING_OFF_CQ_RING = 0x8000000
+	IORING_OFF_SQES    = 0x10000000
+
+	IORING_FEAT_SINGLE_MMAP = 1 << 0
+	IORING_ENTER_GETEVENTS  = 1 << 0
+	IORING_REGISTER_BUFFERS = 0
+	IORING_OP_READV         = 1
+	IORING_OP_READ_FIXED    = 4
+)
+
+type uringSQRingOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringCQRingOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFd uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  uringSQRingOffsets
+	cqOff                                                                  uringCQRingOffsets
+}
+
+// uringSQE is a submission queue entry, as used by the read operations
+type uringSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	_           uint64
+}
+
+// uringCQE is a completion queue entry
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// uring is an io_uring instance, with its queues mapped in memory
+type uring struct {
+	fd             int
+	sqRing, cqRing []byte // the same mapping with IORING_FEAT_SINGLE_MMAP
+	sqesMapping    []byte
+	sqHead, sqTail *uint32
+	sqMask         uint32
+	sqArray        []uint32
+	sqes           []uringSQE
+	cqHead, cqTail *uint32
+	cqMask         uint32
+	cqes           []uringCQE
+	pending        uint32 // entries queued but not submitted yet
+}
+
+func newURing(entries uint32) (*uring, error) {
+	var params uringParams
+	fd, _, errno := syscall.Syscall(SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&params)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("%w: io_uring_setup: %v", errNoURing, errno)
+	}
+	u := &uring{fd: int(fd)}
+
+	sqSize := int(params.sqOff.array + params.sqEntries*4)
+	cqSize := int(params.cqOff.cqes + params.cqEntries*uint32(unsafe.Sizeof(uringCQE{})))
+	single := params.features&IORING_FEAT_SINGLE_MMAP != 0
+	if single {
+		sqSize = max(sqSize, cqSize)
+	}
+
+	var err error
+	u.sqRing, err = mmapRing(u.fd, IORING_OFF_SQ_RING, sqSize)
+	if err == nil && single {
+		u.cqRing = u.sqRing
+	} else if err == nil {
+		u.cqRing, err = mmapRing(u.fd, IORING_OFF_CQ_RING, cqSize)
+	}
+	if err == nil {
+		u.sqesMapping, err = mmapRing(u.fd, IORING_OFF_SQES, int(params.sqEntries)*int(unsafe.Sizeof(uringSQE{})))
+	}
+	if err != nil {
+		u.close()
+		return nil, fmt.Errorf("%w: mapping the queues: %v", errNoURing, err)
+	}
+
+	u.sqHead = ringField(u.sqRing, params.sqOff.head)
+	u.sqTail = ringField(u.sqRing, params.sqOff.tail)
+	u.sqMask = *ringField(u.sqRing, params.sqOff.ringMask)
+	u.sqArray = unsafe.Slice(ringField(u.sqRing, params.sqOff.array), params.sqEntries)
+	u.sqes = unsafe.Slice((*uringSQE)(unsafe.Pointer(&u.sqesMapping[0])), params.sqEntries)
+
+	u.cqHead = ringField(u.cqRing, params.cqOff.head)
+	u.cqTail = ringField(u.cqRing, params.cqOff.tail)
+	u.cqMask = *ringField(u.cqRing, params.cqOff.ringMask)
+	u.cqes = unsafe.Slice((*uringCQE)(unsafe.Pointer(&u.cqRing[params.cqOff.cqes])), params.cqEntries)
+	return u, nil
+}
+
+func mmapRing(fd int, offset int64, size int) ([]byte, error) {
+	return syscall.Mmap(fd, offset, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+}
+
+func ringField(ring []byte, offset uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&ring[offset]))
+}
+
+// registerBuffers registers bufs for the fixed reads
+func (u *uring) registerBuffers(bufs [][]byte) error {
+	iovecs := make([]syscall.Iovec, len(bufs))
+	for i, buf := range bufs {
+		iovecs[i].Base = &buf[0]
+		iovecs[i].SetLen(len(buf))
+	}
+	_, _, errno := syscall.Syscall6(SYS_IO_URING_REGISTER, uintptr(u.fd), IORING_REGISTER_BUFFERS, uintptr(unsafe.Pointer(&iovecs[0])), uintptr(len(iovecs)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// queue adds sqe to the submission queue, to be submitted by the next
+// call to submitAndWait. The queue is never full, as there are never more
+// reads in flight than entries
+func (u *uring) queue(sqe uringSQE) {
+	tail := atomic.LoadUint32(u.sqTail) + u.pending
+	i := tail & u.sqMask
+	u.sqes[i] = sqe
+	u.sqArray[i] = i
+	u.pending++
+}
+
+// submitAndWait publishes the queued entries and submits them, waiting
+// for at least a completion if wait is set
+func (u *uring) submitAndWait(wait bool) error {
+	atomic.StoreUint32(u.sqTail, atomic.LoadUint32(u.sqTail)+u.pending)
+	toSubmit := u.pending
+	u.pending = 0
+
+	var minComplete, flags uintptr
+	if wait {
+		minComplete, flags = 1, IORING_ENTER_GETEVENTS
+	}
+	for {
+		n, _, errno := syscall.Syscall6(SYS_IO_URING_ENTER, uintptr(u.fd), uintptr(toSubmit), minComplete, flags, 0, 0)
+		switch errno {
+		case 0:
+			toSubmit -= uint32(n)
+			if toSubmit == 0 {
+				return nil
+			}
+		case syscall.EINTR:
+		default:
+			return fmt.Errorf("io_uring_enter: %w", errno)
+		}
+	}
+}
+
+// reap calls f with every completion available
+func (u *uring) reap(f func(cqe uringCQE)) {
+	head := atomic.LoadUint32(u.cqHead)
+	tail := atomic.LoadUint32(u.cqTail)
+	for ; head != tail; head++ {
+		f(u.cqes[head&u.cqMask])
+	}
+	atomic.StoreUint32(u.cqHead, head)
+}
+
+func (u *uring) close() error {
+	if u.sqesMapping != nil {
+		syscall.Munmap(u.sqesMapping)
+	}
+	if u.cqRing != nil && &u.cqRing[0] != &u.sqRing[0] {
+		syscall.Munmap(u.cqRing)
+	}
+	if u.sqRing != nil {
+		syscall.Munmap(u.sqRing)
+	}
+	return syscall.Close(u.fd)
+}
+
+// uringRead is the read of the bytes of a chunk into one of the buffers
+// of a uringReader, which may take several reads if they are short
+type uringRead struct {
+	index int // of the buffer, which is also the user data of its reads
+	buf   []byte
+	off   int64 // of the first byte of buf in the file
+	size  int   // bytes to read, or 0 once the chunk is read entirely
+	done  int   // bytes read so far
+	err   error
+	iov   syscall.Iovec // of the rest of buf, for IORING_OP_READV
+}
+
+// uringReader reads its chunk with a read per buffer, keeping all the
+// buffers in flight but the one being parsed. The reads complete in any
+// order, but their bytes are given in the order of the file
+type uringReader struct {
+	u        *uring
+	f        *os.File
+	fixed    bool  // the buffers are registered
+	pos, to  int64 // of the bytes not submitted yet
+	bufs     []byte
+	reads    []uringRead
+	parsed   *uringRead // given by the last call to next, to be reused
+	inFlight int        // reads submitted or queued, not completed yet
+}
+
+func newURingReader(f *os.File, from int64, to int64, bufferSize int, depth int) (chunkReader, error) {
+	r, err := openURingReader(f, from, to, bufferSize, depth, true)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// openURingReader is newURingReader, which tries to register the buffers
+// only if register is set
+func openURingReader(f *os.File, from int64, to int64, bufferSize int, depth int, register bool) (*uringReader, error) {
+	depth = int(min(int64(depth), (to-from+int64(bufferSize)-1)/int64(bufferSize)))
+	u, err := newURing(uint32(depth))
+	if err != nil {
+		return nil, err
+	}
+
+	// the buffers are mapped outside of the heap of Go, where the kernel
+	// can keep them pinned
+	bufs, err := syscall.Mmap(-1, 0, depth*bufferSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		u.close()
+		return nil, err
+	}
+
+	r := &uringReader{u: u, f: f, pos: from, to: to, bufs: bufs, reads: make([]uringRead, depth)}
+	views := make([][]byte, depth)
+	for i := range r.reads {
+		views[i] = bufs[i*bufferSize : (i+1)*bufferSize : (i+1)*bufferSize]
+		r.reads[i] = uringRead{index: i, buf: views[i]}
+	}
+
+	// registering the buffers saves the kernel from mapping them for
+	// every read, but counts against RLIMIT_MEMLOCK: the reads work
+	// without it, with IORING_OP_READV which is as old as the fixed ones
+	// unlike IORING_OP_READ
+	r.fixed = register && u.registerBuffers(views) == nil
+
+	for i := range r.reads {
+		r.submit(&r.reads[i])
+	}
+	err = u.submitAndWait(false)
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// submit queues the read of the next bytes of the chunk into read, if
+// there are any left
+func (r *uringReader) submit(read *uringRead) {
+	read.off = r.pos
+	read.size = int(min(int64(len(read.buf)), r.to-r.pos))
+	read.done = 0
+	read.err = nil
+	r.pos += int64(read.size)
+	if read.size > 0 {
+		r.queueRest(read)
+	}
+}
+
+// queueRest queues the read of what is left to read into read
+func (r *uringReader) queueRest(read *uringRead) {
+	sqe := uringSQE{
+		fd:       int32(r.f.Fd()),
+		off:      uint64(read.off + int64(read.done)),
+		userData: uint64(read.index),
+	}
+	if r.fixed {
+		sqe.opcode = IORING_OP_READ_FIXED
+		sqe.addr = uint64(uintptr(unsafe.Pointer(&read.buf[read.done])))
+		sqe.len = uint32(read.size - read.done)
+		sqe.bufIndex = uint16(read.index)
+	} else {
+		// the iovec stays in read until the completion, as the kernel may
+		// only look at it once the read goes asynchronous
+		read.iov.Base = &read.buf[read.done]
+		read.iov.SetLen(read.size - read.done)
+		sqe.opcode = IORING_OP_READV
+		sqe.addr = uint64(uintptr(unsafe.Pointer(&read.iov)))
+		sqe.len = 1
+	}
+	r.u.queue(sqe)
+	r.inFlight++
+}
+
+// wait submits the queued reads and handles the completions, waiting for
+// at least one. The rest of the short reads is read again, unless retry
+// is not set
+func (r *uringReader) wait(retry bool) error {
+	err := r.u.submitAndWait(true)
+	if err != nil {
+		return err
+	}
+
+	r.u.reap(func(cqe uringCQE) {
+		r.inFlight--
+		read := &r.reads[cqe.userData]
+		switch {
+		case cqe.res < 0:
+			read.err = syscall.Errno(-cqe.res)
+		case cqe.res == 0:
+			// the file is shorter than when it was split in chunks
+			read.err = io.ErrUnexpectedEOF
+		default:
+			read.done += int(cqe.res)
+			if read.done < read.size && retry {
+				r.queueRest(read)
+			}
+		}
+	})
+	return nil
+}
+
+func (r *uringReader) next() ([]byte, error) {
+	if r.parsed != nil {
+		// the buffer parsed last is reused for the bytes after the ones
+		// in flight
+		r.submit(r.parsed)
+		r.parsed = nil
+	}
+
+	// the reads cover the rest of the chunk, so its next bytes are in the
+	// one with the lowest offset
+	var first *uringRead
+	for i := range r.reads {
+		read := &r.reads[i]
+		if read.size > 0 && (first == nil || read.off < first.off) {
+			first = read
+		}
+	}
+	if first == nil {
+		return nil, io.EOF
+	}
+
+	for first.done < first.size && first.err == nil {
+		err := r.wait(true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if first.err != nil {
+		return nil, fmt.Errorf("%s: reading %d bytes at %d: %w", r.f.Name(), first.size-first.done, first.off+int64(first.done), first.err)
+	}
+
+	r.parsed = first
+	return first.buf[:first.size], nil
+}
+
+// close waits for the reads in flight, as the kernel could still write to
+// the buffers, and releases the ring and the buffers
+func (r *uringReader) close() error {
+	for r.inFlight > 0 {
+		if r.wait(false) != nil {
+			// the buffers could still be written: better leak them
+			return r.u.close()
+		}
+	}
+
+	err := r.u.close()
+	syscall.Munmap(r.bufs)
+	return err
+}
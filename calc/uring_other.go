@@ -0,0 +1,13 @@
+//go:build !(linux && (amd64 || arm64 || riscv64 || loong64))
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+func newURingReader(f *os.File, from int64, to int64, bufferSize int, depth int) (chunkReader, error) {
+	return nil, fmt.Errorf("%w on %s/%s", errNoURing, runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+)
+
+// rangeTask asks a worker process for the partial result of the lines
+// between From and To, which must start and end at the start of a line
+type rangeTask struct {
+	Path       string
+	From, To   int64
+	CRLF       bool
+	BufferSize int
+}
+
+// rangeResult is the answer of a worker process to a rangeTask: the
+// stations of the range sorted by name, or why they could not be computed
+type rangeResult struct {
+	Stations []wireStation
+	Err      string
+}
+
+// wireStation is a WeatherStationInfo as sent between processes
+type wireStation struct {
+	Name     string
+	Min, Max int16
+	Acc      int64
+	Count    int
+}
+
+// workerMain implements "calc worker", which computes the ranges sent by
+// a coordinator: on its standard input and output when started by it, or
+// on the TCP connections to the -listen address otherwise. Whoever can
+// connect can read the files the worker can, unless they are confined
+// to -root, so it must only listen on trusted networks
+func workerMain(args []string) {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	listen := fs.String("listen", "", "address to accept the connections of the coordinators on, instead of the standard input and output")
+	root := fs.String("root", "", "directory the files of the tasks must be under (default any file)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s worker [ options ]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *listen == "" {
+		err := serveWorker(os.Stdin, os.Stdout, *root)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Waiting for tasks on %s\n", l.Addr())
+	serveWorkerConns(l, *root)
+}
+
+// serveWorkerConns serves the connections accepted by l, each one from a
+// different coordinator or slot of a coordinator, until l is closed
+func serveWorkerConns(l net.Listener, root string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+			err := serveWorker(conn, conn, root)
+			if err != nil {
+				log.Println(conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// serveWorker answers the tasks read from in until it is closed. If root
+// is not empty, the files of the tasks must be under it
+func serveWorker(in io.Reader, out io.Writer, root string) error {
+	dec := gob.NewDecoder(in)
+	enc := gob.NewEncoder(out)
+
+	for {
+		var task rangeTask
+		err := dec.Decode(&task)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var result rangeResult
+		stations, err := computeRange(task, root)
+		if err != nil {
+			result.Err = err.Error()
+		}
+		result.Stations = toWire(stations)
+
+		err = enc.Encode(&result)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// computeRange computes the lines of a task, which start at task.From
+// and end at task.To: the last one may have no newline at the end of
+// the file. If root is not empty, the file must be under it
+func computeRange(task rangeTask, root string) ([]*WeatherStationInfo, error) {
+	if task.From < 0 || task.To < task.From || task.BufferSize < 1 {
+		return nil, fmt.Errorf("invalid range %d-%d with a buffer of %d bytes", task.From, task.To, task.BufferSize)
+	}
+	path := task.Path
+	if root != "" {
+		var err error
+		path, err = resolveUnder(root, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", task.Path, err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// compute leaves out the first line and the last one, if it has no
+	// newline, as they could be shared with other chunks
+	var of overflow
+	r, err := preadBackend{}.open(f, task.From, task.To, task.BufferSize)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close()
+	partial, err := compute(context.Background(), r, task.CRLF, &of, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	leftover := computeOverflows([]overflow{of}, task.CRLF)
+
+	return mergeMatrix([][]*WeatherStationInfo{partial, leftover}), nil
+}
+
+func toWire(stations []*WeatherStationInfo) []wireStation {
+	result := make([]wireStation, len(stations))
+	for i, wsi := range stations {
+		result[i] = wireStation{
+			Name: wsi.name,
+			Min:  wsi.min, Max: wsi.max,
+			Acc: wsi.acc, Count: wsi.count,
+		}
+	}
+	return result
+}
+
+func fromWire(stations []wireStation) []*WeatherStationInfo {
+	result := make([]*WeatherStationInfo, len(stations))
+	for i, ws := range stations {
+		result[i] = &WeatherStationInfo{
+			name: ws.Name,
+			min:  ws.Min, max: ws.Max,
+			acc: ws.Acc, count: ws.Count,
+		}
+	}
+	return result
+}
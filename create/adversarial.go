@@ -0,0 +1,377 @@
+package main
+
+import (
+    "crypto/sha256"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "math/rand/v2"
+    "os"
+    "runtime"
+    "slices"
+    "strings"
+    "time"
+
+    "create/generator"
+)
+
+// MAX_LINE_LENGTH is the length of a line with the longest name and
+// temperature, like "<name>;-99.9\n"
+const MAX_LINE_LENGTH = generator.MAX_NAME_LENGTH + 7
+
+// MIN_LINE_LENGTH is the length of a line like "a;0.0\n"
+const MIN_LINE_LENGTH = 6
+
+// BOUNDARY_MARGIN is the distance from a boundary below which only the
+// line straddling it is written
+const BOUNDARY_MARGIN = 60
+
+// adversarialMain implements "create adversarial", which writes measurements
+// built to break the implementations rather than to measure them
+func adversarialMain(args []string) {
+    fs := flag.NewFlagSet("adversarial", flag.ExitOnError)
+    seed := fs.Uint64("seed", 0, "seed of the generated data: if 0 it is chosen at random")
+    workers := fs.Int("workers", runtime.NumCPU() * 20, "number of workers of the target implementation, which splits the file in as many chunks")
+    bufferSize := fs.Int("buffer", 1024 * 1024, "size of the buffer the target implementation reads its chunks with")
+    path := fs.String("o", PATH + "-adversarial.txt", "path of the measurements")
+
+    fs.Usage = func() {
+        fmt.Fprintf(fs.Output(), "Usage: %s adversarial [ options ] <size to create, like 64MiB>\n", os.Args[0])
+        fs.PrintDefaults()
+    }
+    fs.Parse(args)
+
+    if fs.NArg() < 1 {
+        fs.Usage()
+        os.Exit(2)
+    }
+
+    size, err := generator.ParseTarget(fs.Arg(0))
+    if err != nil {
+        log.Fatalln(err)
+    }
+    if size.Bytes < 1024 {
+        log.Fatalln("The size of an adversarial file must be given in bytes, and be at least 1KB")
+    }
+    if *workers < 1 || *bufferSize < 1 {
+        log.Fatalln("The number of workers and the buffer size must be positive")
+    }
+
+    if *seed == 0 {
+        *seed = rand.Uint64()
+    }
+    fmt.Fprintf(console, "Using seed %d\n", *seed)
+
+    start := time.Now()
+    a := newAdversary(rand.New(rand.NewPCG(*seed, 0)))
+    a.generate(size.Bytes, boundaries(size.Bytes, *workers, *bufferSize))
+
+    f, err := os.Create(*path)
+    if err != nil {
+        log.Fatalln(err)
+    }
+    defer f.Close()
+
+    _, err = f.Write(a.data)
+    if err != nil {
+        log.Fatalln(err)
+    }
+    fmt.Fprintf(console, "Created %s with %d measurements (%d bytes) in %v\n", *path, a.lines, len(a.data), time.Since(start))
+
+    resPath := resultPath(*path)
+    resultSum := sha256.New()
+    res, err := os.Create(resPath)
+    if err != nil {
+        log.Fatalln(err)
+    }
+    defer res.Close()
+
+    err = generator.WriteResult(io.MultiWriter(res, resultSum), a.stations, a.aggregates)
+    if err != nil {
+        log.Fatalln(err)
+    }
+    fmt.Fprintf(console, "Written result at <%s>\n", resPath)
+
+    options := map[string]string{
+        "mode": "adversarial",
+        "workers": fmt.Sprint(*workers),
+        "buffer": fmt.Sprint(*bufferSize),
+    }
+    manPath := manifestPath(*path)
+    sum := sha256.Sum256(a.data)
+    stats := generator.Stats{
+        Rows: int64(a.lines),
+        Bytes: int64(len(a.data)),
+        SHA256: sum[:],
+        Shards: []generator.ShardStats{ { Bytes: int64(len(a.data)), SHA256: sum[:] } },
+    }
+    m := newManifest(*seed, fs.Arg(0), stats, []string{ *path }, a.aggregates, options, resPath, resultSum.Sum(nil))
+    err = m.write(manPath)
+    if err != nil {
+        log.Fatalln(err)
+    }
+    fmt.Fprintf(console, "Written manifest at <%s>\n", manPath)
+}
+
+// boundaries returns the offsets, in a file of the given size, where an
+// implementation with the given number of workers and buffer size stops
+// reading: calc splits the file in one chunk per worker and reads every
+// chunk bufferSize bytes at a time, solution reads the whole file that
+// way. The offsets are sorted and unique
+func boundaries(size int64, workers int, bufferSize int) []int64 {
+    var result []int64
+    for i := range int64(workers) {
+        from, to := size * i / int64(workers), size * (i+1) / int64(workers)
+        for b := from; b < to; b += int64(bufferSize) {
+            result = append(result, b)
+        }
+    }
+    for b := int64(0); b < size; b += int64(bufferSize) {
+        result = append(result, b)
+    }
+
+    slices.Sort(result)
+    result = slices.Compact(result)
+    // there is no line to straddle at the start of the file
+    return result[1:]
+}
+
+// adversary builds the measurements in memory, adding up the values of
+// every station as it goes
+type adversary struct {
+    r *rand.Rand
+
+    data  []byte
+    lines int
+    queue []measurement // lines waiting for some room between boundaries
+
+    index      map[string]int // position of every station in stations
+    stations   []generator.Station
+    aggregates []generator.Aggregate
+
+    // stations reused by the runs and the collisions, so that the number
+    // of stations stays far below the limit of the challenge
+    fnvPairs  [][2]string
+    djb2Pairs [][2]string
+    longNames []string
+}
+
+type measurement struct {
+    name string
+    temp int64
+}
+
+// multiByte holds characters of 2, 3 and 4 bytes to straddle boundaries
+var multiByte = [][]rune{
+    2: { 'é', 'ж', 'Ω' },
+    3: { '東', '€', 'ก' },
+    4: { '𝄞', '😀', '𠜎' },
+}
+
+func newAdversary(r *rand.Rand) *adversary {
+    a := &adversary{
+        r: r,
+        index: make(map[string]int),
+    }
+
+    suffixes := []string{ "", "-1", " Nord", "東京", "é" }
+    for _, pair := range fnvCollisions {
+        for _, suffix := range suffixes {
+            a.fnvPairs = append(a.fnvPairs, [2]string{ pair[0] + suffix, pair[1] + suffix })
+        }
+    }
+
+    for range 32 {
+        x, y := djb2Collision(r, 2 + r.IntN(generator.MAX_NAME_LENGTH - 1))
+        a.djb2Pairs = append(a.djb2Pairs, [2]string{ x, y })
+    }
+
+    // names of exactly MAX_NAME_LENGTH bytes, with characters of every
+    // length and an ASCII letter to fill up the odd ones
+    for length := 1; length <= 4; length++ {
+        for range 4 {
+            var sb strings.Builder
+            for sb.Len() + length <= generator.MAX_NAME_LENGTH {
+                if length == 1 {
+                    sb.WriteByte(byte('a' + r.IntN(26)))
+                } else {
+                    chars := multiByte[length]
+                    sb.WriteRune(chars[r.IntN(len(chars))])
+                }
+            }
+            for sb.Len() < generator.MAX_NAME_LENGTH {
+                sb.WriteByte('z')
+            }
+            a.longNames = append(a.longNames, sb.String())
+        }
+    }
+
+    return a
+}
+
+// generate writes exactly size bytes of measurements, with a multi-byte
+// character across each of the boundaries that are not too close to the
+// end of the file. The other lines are collisions, long lines and runs
+func (a *adversary) generate(size int64, boundaries []int64) {
+    a.data = make([]byte, 0, size)
+
+    for _, b := range boundaries {
+        if b > size - 2 * MAX_LINE_LENGTH {
+            break
+        }
+        if b <= a.pos() {
+            // the previous line went past it
+            continue
+        }
+
+        a.fill(b - BOUNDARY_MARGIN - MAX_LINE_LENGTH)
+        a.approach(b)
+        a.straddle(b)
+    }
+
+    a.fill(size - 2 * MAX_LINE_LENGTH)
+    a.finish(size)
+}
+
+func (a *adversary) pos() int64 {
+    return int64(len(a.data))
+}
+
+// fill writes the queued lines while they end before limit
+func (a *adversary) fill(limit int64) {
+    for {
+        if len(a.queue) == 0 {
+            a.enqueue()
+        }
+
+        m := a.queue[0]
+        if a.pos() + int64(len(m.name) + len(generator.FormatTenths(m.temp)) + 2) > limit {
+            return
+        }
+        a.queue = a.queue[1:]
+        a.add(m.name, m.temp)
+    }
+}
+
+// approach writes filler lines until b is close enough to be straddled
+// by a single line
+func (a *adversary) approach(b int64) {
+    for gap := b - a.pos(); gap > BOUNDARY_MARGIN; gap = b - a.pos() {
+        a.filler(int(min(MAX_LINE_LENGTH, gap - BOUNDARY_MARGIN / 2)))
+    }
+}
+
+// finish writes filler lines until the file is exactly size bytes long,
+// which must be at least MIN_LINE_LENGTH bytes away
+func (a *adversary) finish(size int64) {
+    for gap := size - a.pos(); gap > 0; gap = size - a.pos() {
+        if gap <= MAX_LINE_LENGTH {
+            a.filler(int(gap))
+        } else {
+            // leaves room for a last line of any length
+            a.filler(int(min(MAX_LINE_LENGTH, gap - MIN_LINE_LENGTH)))
+        }
+    }
+}
+
+// straddle writes a line whose name has a multi-byte character starting
+// before b and ending after it
+func (a *adversary) straddle(b int64) {
+    gap := int(b - a.pos())
+    length := 2 + a.r.IntN(3)
+    before := min(1 + a.r.IntN(length - 1), gap)
+
+    chars := multiByte[length]
+    name := strings.Repeat("Straddling-", 6)[:gap - before] + string(chars[a.r.IntN(len(chars))])
+    name += []string{ "", "s", "-Ville", " 東" }[a.r.IntN(4)]
+    a.add(name, a.temp())
+}
+
+// filler writes a line of exactly length bytes, between MIN_LINE_LENGTH
+// and MAX_LINE_LENGTH
+func (a *adversary) filler(length int) {
+    digits := 3 + a.r.IntN(3)
+    digits = max(min(digits, length - 3), length - 2 - generator.MAX_NAME_LENGTH)
+
+    var temp int64
+    switch digits {
+    case 3:
+        temp = a.r.Int64N(100)
+    case 4:
+        temp = 100 + a.r.Int64N(900)
+        if a.r.IntN(2) == 0 {
+            temp = -temp / 10
+        }
+    case 5:
+        temp = -100 - a.r.Int64N(900)
+    }
+
+    name := strings.Repeat("Padding-station-", 7)[:length - 2 - digits]
+    a.add(name, temp)
+}
+
+// enqueue adds a group of adversarial lines to the queue
+func (a *adversary) enqueue() {
+    switch a.r.IntN(4) {
+    case 0:
+        // stations with the same FNV-1a hash, in both orders
+        pair := a.fnvPairs[a.r.IntN(len(a.fnvPairs))]
+        a.queue = append(a.queue,
+            measurement{ pair[0], a.temp() },
+            measurement{ pair[1], a.temp() },
+            measurement{ pair[1], a.temp() },
+            measurement{ pair[0], a.temp() },
+        )
+    case 1:
+        // stations with the same djb2 hash
+        pair := a.djb2Pairs[a.r.IntN(len(a.djb2Pairs))]
+        a.queue = append(a.queue,
+            measurement{ pair[0], a.temp() },
+            measurement{ pair[1], a.temp() },
+        )
+    case 2:
+        // the longest lines allowed
+        name := a.longNames[a.r.IntN(len(a.longNames))]
+        a.queue = append(a.queue,
+            measurement{ name, generator.MIN_TENTHS },
+            measurement{ name, generator.MAX_TENTHS },
+        )
+    case 3:
+        // a run of the same station
+        var name string
+        switch a.r.IntN(3) {
+        case 0:
+            name = generator.BuiltinStations[a.r.IntN(len(generator.BuiltinStations))].Name
+        case 1:
+            name = a.longNames[a.r.IntN(len(a.longNames))]
+        case 2:
+            name = a.fnvPairs[a.r.IntN(len(a.fnvPairs))][a.r.IntN(2)]
+        }
+        for range 16 + a.r.IntN(500) {
+            a.queue = append(a.queue, measurement{ name, a.temp() })
+        }
+    }
+}
+
+func (a *adversary) temp() int64 {
+    return generator.MIN_TENTHS + a.r.Int64N(generator.MAX_TENTHS - generator.MIN_TENTHS + 1)
+}
+
+// add writes a line and adds it to the aggregates of its station
+func (a *adversary) add(name string, temp int64) {
+    a.data = append(a.data, name...)
+    a.data = append(a.data, ';')
+    a.data = generator.AppendTenths(a.data, temp)
+    a.data = append(a.data, '\n')
+    a.lines++
+
+    i, found := a.index[name]
+    if !found {
+        i = len(a.stations)
+        a.index[name] = i
+        a.stations = append(a.stations, generator.Station{ Name: name })
+        a.aggregates = append(a.aggregates, generator.Aggregate{})
+    }
+    a.aggregates[i].Add(temp)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "hash/fnv"
+    "math/rand/v2"
+    "strings"
+    "testing"
+    "unicode/utf8"
+
+    "create/generator"
+)
+
+var search = flag.Bool("search", false, "search for a new pair of names with the same FNV-1a hash, which takes minutes")
+
+func TestCollisions(t *testing.T) {
+    a := newAdversary(rand.New(rand.NewPCG(1, 2)))
+
+    for _, pair := range a.fnvPairs {
+        if pair[0] == pair[1] || fnv1a([]byte(pair[0])) != fnv1a([]byte(pair[1])) {
+            t.Errorf("%q and %q: not an FNV-1a collision", pair[0], pair[1])
+        }
+
+        // the same hash as calc
+        h := fnv.New64a()
+        h.Write([]byte(pair[0]))
+        if h.Sum64() != fnv1a([]byte(pair[0])) {
+            t.Errorf("%q: fnv1a differs from hash/fnv", pair[0])
+        }
+    }
+
+    for _, pair := range a.djb2Pairs {
+        if pair[0] == pair[1] || djb2([]byte(pair[0])) != djb2([]byte(pair[1])) {
+            t.Errorf("%q and %q: not a djb2 collision", pair[0], pair[1])
+        }
+        if strings.ContainsAny(pair[1], ";\n") {
+            t.Errorf("%q: invalid name", pair[1])
+        }
+    }
+}
+
+func TestSearchCollision(t *testing.T) {
+    if !*search {
+        t.Skip("run with -search")
+    }
+
+    a, b := searchCollision(fnv1a, rand.New(rand.NewPCG(rand.Uint64(), 0)))
+    if fnv1a([]byte(a)) != fnv1a([]byte(b)) {
+        t.Fatalf("%q and %q: not a collision", a, b)
+    }
+    t.Logf("{ %q, %q },", a, b)
+}
+
+// searchCollision looks for two different names of 16 letters with the
+// same hash, with Pollard's rho method and distinguished points: every
+// trail walks x -> hash(name(x)) from a random start until the low bits
+// of x are zero, and two trails ending at the same point have merged
+// after a collision, which is then found by walking them again in step.
+// It takes around 2^32 hashes for a 64 bits hash
+func searchCollision(hash func([]byte) uint64, r *rand.Rand) (string, string) {
+    const mask = 1<<22 - 1
+
+    var buf [16]byte
+    name := func(x uint64) []byte {
+        for i := range buf {
+            buf[i] = 'a' + byte(x >> (4*i) & 15)
+        }
+        return buf[:]
+    }
+    step := func(x uint64) uint64 {
+        return hash(name(x))
+    }
+
+    type trail struct{ start, length uint64 }
+    ends := make(map[uint64]trail)
+
+    for {
+        t := trail{ start: r.Uint64() }
+        x := t.start
+        for x & mask != 0 && t.length < 64 * mask {
+            x = step(x)
+            t.length++
+        }
+        if x & mask != 0 {
+            // a cycle without distinguished points
+            continue
+        }
+
+        other, found := ends[x]
+        if !found {
+            ends[x] = t
+            continue
+        }
+
+        a, b := other.start, t.start
+        for la, lb := other.length, t.length; la != lb; {
+            if la > lb {
+                a, la = step(a), la-1
+            } else {
+                b, lb = step(b), lb-1
+            }
+        }
+        if a == b {
+            // one trail started on the other
+            continue
+        }
+
+        for step(a) != step(b) {
+            a, b = step(a), step(b)
+        }
+        return string(name(a)), string(name(b))
+    }
+}
+
+func TestAdversarial(t *testing.T) {
+    for _, tt := range []struct {
+        size       int64
+        workers    int
+        bufferSize int
+    }{
+        { 1024, 1, 64 },
+        { 16 * 1024, 3, 100 },
+        { 16 * 1024, 64, 16 },
+        { 256 * 1024, 8, 4096 },
+    } {
+        t.Run(fmt.Sprintf("size=%d/workers=%d/buffer=%d", tt.size, tt.workers, tt.bufferSize), func(t *testing.T) {
+            a := newAdversary(rand.New(rand.NewPCG(42, 0)))
+            offsets := boundaries(tt.size, tt.workers, tt.bufferSize)
+            a.generate(tt.size, offsets)
+
+            if int64(len(a.data)) != tt.size {
+                t.Fatalf("got %d bytes, want %d", len(a.data), tt.size)
+            }
+
+            // every boundary far enough from the end is in the middle of
+            // a character, unless it is so close to the previous one that
+            // the line straddling that went past it: the end of the
+            // character, the suffix and the temperature take 16 bytes
+            var straddled int
+            for i, b := range offsets {
+                if b > tt.size - 2 * MAX_LINE_LENGTH {
+                    break
+                }
+                if !utf8.RuneStart(a.data[b]) {
+                    straddled++
+                } else if i == 0 || b - offsets[i-1] > 16 {
+                    t.Errorf("boundary %d is not straddled", b)
+                }
+            }
+            if straddled == 0 {
+                t.Error("no boundary is straddled")
+            }
+
+            lines := bytes.Split(bytes.TrimSuffix(a.data, []byte("\n")), []byte("\n"))
+            for i, line := range lines {
+                name, _, found := bytes.Cut(line, []byte(";"))
+                if !found || len(name) == 0 || len(name) > generator.MAX_NAME_LENGTH || !utf8.Valid(name) {
+                    t.Fatalf("line %d: invalid %q", i+1, line)
+                }
+            }
+            if len(lines) != a.lines {
+                t.Errorf("got %d lines, counted %d", len(lines), a.lines)
+            }
+            if len(a.stations) > 10000 {
+                t.Errorf("%d stations", len(a.stations))
+            }
+
+            var got, want bytes.Buffer
+            err := generator.WriteResult(&got, a.stations, a.aggregates)
+            if err != nil {
+                t.Fatal(err)
+            }
+            reference(bytes.NewReader(a.data), &want)
+
+            if diff := diffResults(got.String(), want.String()); diff != "" {
+                t.Error(diff)
+            }
+        })
+    }
+}
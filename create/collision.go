@@ -0,0 +1,52 @@
+package main
+
+import (
+    "math/rand/v2"
+)
+
+// fnv1a is the 64 bits FNV-1a hash that calc keys its stations on
+func fnv1a(name []byte) uint64 {
+    h := uint64(14695981039346656037)
+    for _, c := range name {
+        h ^= uint64(c)
+        h *= 1099511628211
+    }
+    return h
+}
+
+// djb2 is the hash that solution keys its stations on
+func djb2(name []byte) uint64 {
+    var h uint64 = 5381
+    for _, c := range name {
+        h = (h << 5) + h + uint64(c)
+    }
+    return h
+}
+
+// fnvCollisions are pairs of names with the same fnv1a hash, found by
+// searchCollision (go test -run SearchCollision -search). Both hashes
+// only depend on the previous state and on the next byte, so the same
+// suffix can be appended to both names of a pair to make more of them
+var fnvCollisions = [][2]string{
+    { "ehamapahcjnkgobd", "eppdcgedaogegdjn" },
+}
+
+// djb2Collision returns two different names of length bytes with the same
+// djb2 hash. Raising a byte by one and lowering the next one by 33 leaves
+// the hash unchanged, so the pair is a random name of lowercase letters
+// and the same with one such change, which gives printable ASCII between
+// '@' and '{' but never ';'
+func djb2Collision(r *rand.Rand, length int) (string, string) {
+    a := make([]byte, length)
+    for i := range a {
+        a[i] = byte('a' + r.IntN(26))
+    }
+
+    b := make([]byte, length)
+    copy(b, a)
+    i := r.IntN(length - 1)
+    b[i]++
+    b[i+1] -= 33
+
+    return string(a), string(b)
+}
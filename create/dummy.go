@@ -2,26 +2,21 @@ package main
 
 import (
 	"bufio"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"slices"
-	"strconv"
 	"strings"
 	"time"
 
+	"create/generator"
 	"golang.org/x/exp/maps"
 )
 
-type WeatherStationInfo struct {
-    name string
-    min float64
-    max float64
-    acc float64
-    count int
-}
-
-func dummy(measurementsPath string, resultPath string) {
+// dummy computes the result of the measurements split in the given
+// files, in order, which are decompressed if their name ends with ".gz"
+func dummy(measurementsPaths []string, resultPath string) {
     start := time.Now()
 
 	out, err := os.Create(resultPath)
@@ -30,59 +25,83 @@ func dummy(measurementsPath string, resultPath string) {
 	}
 	defer out.Close()
 
-    in, err := os.Open(measurementsPath)
-    if err != nil {
-		log.Fatalln(err)
-	}
-	defer in.Close()
+    readers := make([]io.Reader, 0, len(measurementsPaths))
+    for _, path := range measurementsPaths {
+        f, err := os.Open(path)
+        if err != nil {
+            log.Fatalln(err)
+        }
+        defer f.Close()
+
+        var r io.Reader = f
+        if strings.HasSuffix(path, ".gz") {
+            gz, err := gzip.NewReader(f)
+            if err != nil {
+                log.Fatalln(path, err)
+            }
+            defer gz.Close()
+            r = gz
+        }
+        readers = append(readers, r)
+    }
+
+    reference(io.MultiReader(readers...), out)
 
-    results := make(map[string]WeatherStationInfo)
+    fmt.Fprintf(console, "Generated dummy result at <%s> in %v\n", resultPath, time.Since(start));
+}
+
+// reference is the simplest possible implementation of the challenge,
+// used as the oracle of the optimised ones: it reads the measurements
+// line by line and adds them up in exact integer tenths. It stops the
+// program, reporting the line, on any malformed input
+func reference(in io.Reader, out io.Writer) {
+    results := make(map[string]*generator.Aggregate)
 
     sc := bufio.NewScanner(in)
+    line := 0
     for sc.Scan() {
-        name, tempString, _ := strings.Cut(sc.Text(), ";")
-        temp, err := strconv.ParseFloat(tempString, 64)
-        if err != nil {
-            log.Fatalln(name, err)
+        line++
+
+        // the scanner already drops the '\r' of CRLF lines, but not the
+        // byte order mark at the start of the file
+        text := sc.Text()
+        if line == 1 {
+            text = strings.TrimPrefix(text, "\uFEFF")
         }
 
-        info,found := results[name]
+        name, tempString, found := strings.Cut(text, ";")
         if !found {
-            results[name] = WeatherStationInfo{
-                name: name,
-                min: temp, max: temp,
-                acc: temp, count: 1,
-            }
-        } else {
-            if temp < info.min {
-                info.min = temp
-            }
-            if temp > info.max {
-                info.max = temp
-            }
-
-            info.acc += temp
-            info.count++
+            log.Fatalf("line %d: missing ';' in %q\n", line, text)
+        }
+        if name == "" || len(name) > generator.MAX_NAME_LENGTH {
+            log.Fatalf("line %d: station name of %d bytes\n", line, len(name))
+        }
+        temp, err := generator.ParseTenths(tempString)
+        if err != nil {
+            log.Fatalf("line %d: %v\n", line, err)
+        }
 
+        info, found := results[name]
+        if !found {
+            info = &generator.Aggregate{}
             results[name] = info
         }
+        info.Add(temp)
+    }
+    if err := sc.Err(); err != nil {
+        log.Fatalf("line %d: %v\n", line+1, err)
     }
 
-    ids := maps.Keys(results)
-    slices.Sort(ids)
-
-    fmt.Fprint(out, "{\n")
-    first := true
-    for _, key := range ids {
-        value := results[key]
-        if first {
-            first = false
-            fmt.Fprintf(out, "\t%s=%.1f/%.1f/%.1f", key, value.min, value.acc / float64(value.count), value.max)
-        } else {
-            fmt.Fprintf(out, ",\n\t%s=%.1f/%.1f/%.1f", key, value.min, value.acc / float64(value.count), value.max)
-        }
+    names := maps.Keys(results)
+    stations := make([]generator.Station, len(names))
+    aggregates := make([]generator.Aggregate, len(names))
+    for i, name := range names {
+        stations[i] = generator.Station{ Name: name }
+        aggregates[i] = *results[name]
     }
-    fmt.Fprint(out, "\n}\n")
 
-    fmt.Printf("Generated dummy result at <%s> in %v\n", resultPath, time.Since(start));
-}
\ No newline at end of file
+    err := generator.WriteResult(out, stations, aggregates)
+    if err != nil {
+        log.Fatalln(err)
+    }
+}
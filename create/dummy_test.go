@@ -0,0 +1,62 @@
+package main
+
+import (
+    "bytes"
+    "os"
+    "os/exec"
+    "strings"
+    "testing"
+)
+
+func TestReference(t *testing.T) {
+    for _, tt := range []struct {
+        name string
+        in   string
+        want string
+    }{
+        { "empty", "", "{\n\n}\n" },
+        { "half up", "a;0.1\na;0.2\n", "{\n\ta=0.1/0.2/0.2\n}\n" },
+        { "negative half up", "a;-0.1\na;-0.2\n", "{\n\ta=-0.2/-0.1/-0.1\n}\n" },
+        { "negative zero", "a;-0.0\na;-0.1\n", "{\n\ta=-0.1/0.0/0.0\n}\n" },
+        { "sorted by bytes", "b;1.0\nB;2.0\né;3.0\na;4.0\n", "{\n\tB=2.0/2.0/2.0,\n\ta=4.0/4.0/4.0,\n\tb=1.0/1.0/1.0,\n\té=3.0/3.0/3.0\n}\n" },
+        { "bom and crlf", "\uFEFFa;99.9\r\na;-99.9", "{\n\ta=-99.9/0.0/99.9\n}\n" },
+        { "unclamped", "a;123.4\na;-1000.0\n", "{\n\ta=-1000.0/-438.3/123.4\n}\n" },
+    } {
+        t.Run(tt.name, func(t *testing.T) {
+            var out bytes.Buffer
+            reference(strings.NewReader(tt.in), &out)
+
+            if diff := diffResults(out.String(), tt.want); diff != "" {
+                t.Error(diff)
+            }
+        })
+    }
+}
+
+func TestReferenceMalformed(t *testing.T) {
+    if in := os.Getenv("REFERENCE_INPUT"); in != "" {
+        reference(strings.NewReader(in), &bytes.Buffer{})
+        return
+    }
+
+    for in, want := range map[string]string{
+        "a;1.0\nb;2.0\nc 3.0\n": "line 3: missing ';'",
+        "a;1.0\n;2.0\n":         "line 2: station name of 0 bytes",
+        "a;1\n":                 `line 1: invalid temperature "1"`,
+        "a;1.00\n":              `line 1: invalid temperature "1.00"`,
+        "a;1.0\n\nb;2.0\n":      "line 2: missing ';'",
+        "a;+1.0\n":              `line 1: invalid temperature "+1.0"`,
+        "a;--1.0\n":             `line 1: invalid temperature "--1.0"`,
+    } {
+        // log.Fatal exits, so every input is checked by a new process
+        cmd := exec.Command(os.Args[0], "-test.run=^TestReferenceMalformed$")
+        cmd.Env = append(os.Environ(), "REFERENCE_INPUT=" + in)
+        out, err := cmd.CombinedOutput()
+
+        if err == nil {
+            t.Errorf("%q: expected the reference to fail", in)
+        } else if !strings.Contains(string(out), want) {
+            t.Errorf("%q: got %q, want %q", in, out, want)
+        }
+    }
+}
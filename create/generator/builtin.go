@@ -0,0 +1,419 @@
+package generator
+
+// BuiltinStations are the stations of the original challenge, with their
+// mean temperature
+var BuiltinStations = [...]Station{
+    { Name: "Abha", MeanTemp: 18.0 },
+    { Name: "Abidjan", MeanTemp: 26.0 },
+    { Name: "Abéché", MeanTemp: 29.4 },
+    { Name: "Accra", MeanTemp: 26.4 },
+    { Name: "Addis Ababa", MeanTemp: 16.0 },
+    { Name: "Adelaide", MeanTemp: 17.3 },
+    { Name: "Aden", MeanTemp: 29.1 },
+    { Name: "Ahvaz", MeanTemp: 25.4 },
+    { Name: "Albuquerque", MeanTemp: 14.0 },
+    { Name: "Alexandra", MeanTemp: 11.0 },
+    { Name: "Alexandria", MeanTemp: 20.0 },
+    { Name: "Algiers", MeanTemp: 18.2 },
+    { Name: "Alice Springs", MeanTemp: 21.0 },
+    { Name: "Almaty", MeanTemp: 10.0 },
+    { Name: "Amsterdam", MeanTemp: 10.2 },
+    { Name: "Anadyr", MeanTemp: -6.9 },
+    { Name: "Anchorage", MeanTemp: 2.8 },
+    { Name: "Andorra la Vella", MeanTemp: 9.8 },
+    { Name: "Ankara", MeanTemp: 12.0 },
+    { Name: "Antananarivo", MeanTemp: 17.9 },
+    { Name: "Antsiranana", MeanTemp: 25.2 },
+    { Name: "Arkhangelsk", MeanTemp: 1.3 },
+    { Name: "Ashgabat", MeanTemp: 17.1 },
+    { Name: "Asmara", MeanTemp: 15.6 },
+    { Name: "Assab", MeanTemp: 30.5 },
+    { Name: "Astana", MeanTemp: 3.5 },
+    { Name: "Athens", MeanTemp: 19.2 },
+    { Name: "Atlanta", MeanTemp: 17.0 },
+    { Name: "Auckland", MeanTemp: 15.2 },
+    { Name: "Austin", MeanTemp: 20.7 },
+    { Name: "Baghdad", MeanTemp: 22.77 },
+    { Name: "Baguio", MeanTemp: 19.5 },
+    { Name: "Baku", MeanTemp: 15.1 },
+    { Name: "Baltimore", MeanTemp: 13.1 },
+    { Name: "Bamako", MeanTemp: 27.8 },
+    { Name: "Bangkok", MeanTemp: 28.6 },
+    { Name: "Bangui", MeanTemp: 26.0 },
+    { Name: "Banjul", MeanTemp: 26.0 },
+    { Name: "Barcelona", MeanTemp: 18.2 },
+    { Name: "Bata", MeanTemp: 25.1 },
+    { Name: "Batumi", MeanTemp: 14.0 },
+    { Name: "Beijing", MeanTemp: 12.9 },
+    { Name: "Beirut", MeanTemp: 20.9 },
+    { Name: "Belgrade", MeanTemp: 12.5 },
+    { Name: "Belize City", MeanTemp: 26.7 },
+    { Name: "Benghazi", MeanTemp: 19.9 },
+    { Name: "Bergen", MeanTemp: 7.7 },
+    { Name: "Berlin", MeanTemp: 10.3 },
+    { Name: "Bilbao", MeanTemp: 14.7 },
+    { Name: "Birao", MeanTemp: 26.5 },
+    { Name: "Bishkek", MeanTemp: 11.3 },
+    { Name: "Bissau", MeanTemp: 27.0 },
+    { Name: "Blantyre", MeanTemp: 22.2 },
+    { Name: "Bloemfontein", MeanTemp: 15.6 },
+    { Name: "Boise", MeanTemp: 11.4 },
+    { Name: "Bordeaux", MeanTemp: 14.2 },
+    { Name: "Bosaso", MeanTemp: 30.0 },
+    { Name: "Boston", MeanTemp: 10.9 },
+    { Name: "Bouaké", MeanTemp: 26.0 },
+    { Name: "Bratislava", MeanTemp: 10.5 },
+    { Name: "Brazzaville", MeanTemp: 25.0 },
+    { Name: "Bridgetown", MeanTemp: 27.0 },
+    { Name: "Brisbane", MeanTemp: 21.4 },
+    { Name: "Brussels", MeanTemp: 10.5 },
+    { Name: "Bucharest", MeanTemp: 10.8 },
+    { Name: "Budapest", MeanTemp: 11.3 },
+    { Name: "Bujumbura", MeanTemp: 23.8 },
+    { Name: "Bulawayo", MeanTemp: 18.9 },
+    { Name: "Burnie", MeanTemp: 13.1 },
+    { Name: "Busan", MeanTemp: 15.0 },
+    { Name: "Cabo San Lucas", MeanTemp: 23.9 },
+    { Name: "Cairns", MeanTemp: 25.0 },
+    { Name: "Cairo", MeanTemp: 21.4 },
+    { Name: "Calgary", MeanTemp: 4.4 },
+    { Name: "Canberra", MeanTemp: 13.1 },
+    { Name: "Cape Town", MeanTemp: 16.2 },
+    { Name: "Changsha", MeanTemp: 17.4 },
+    { Name: "Charlotte", MeanTemp: 16.1 },
+    { Name: "Chiang Mai", MeanTemp: 25.8 },
+    { Name: "Chicago", MeanTemp: 9.8 },
+    { Name: "Chihuahua", MeanTemp: 18.6 },
+    { Name: "Chișinău", MeanTemp: 10.2 },
+    { Name: "Chittagong", MeanTemp: 25.9 },
+    { Name: "Chongqing", MeanTemp: 18.6 },
+    { Name: "Christchurch", MeanTemp: 12.2 },
+    { Name: "City of San Marino", MeanTemp: 11.8 },
+    { Name: "Colombo", MeanTemp: 27.4 },
+    { Name: "Columbus", MeanTemp: 11.7 },
+    { Name: "Conakry", MeanTemp: 26.4 },
+    { Name: "Copenhagen", MeanTemp: 9.1 },
+    { Name: "Cotonou", MeanTemp: 27.2 },
+    { Name: "Cracow", MeanTemp: 9.3 },
+    { Name: "Da Lat", MeanTemp: 17.9 },
+    { Name: "Da Nang", MeanTemp: 25.8 },
+    { Name: "Dakar", MeanTemp: 24.0 },
+    { Name: "Dallas", MeanTemp: 19.0 },
+    { Name: "Damascus", MeanTemp: 17.0 },
+    { Name: "Dampier", MeanTemp: 26.4 },
+    { Name: "Dar es Salaam", MeanTemp: 25.8 },
+    { Name: "Darwin", MeanTemp: 27.6 },
+    { Name: "Denpasar", MeanTemp: 23.7 },
+    { Name: "Denver", MeanTemp: 10.4 },
+    { Name: "Detroit", MeanTemp: 10.0 },
+    { Name: "Dhaka", MeanTemp: 25.9 },
+    { Name: "Dikson", MeanTemp: -11.1 },
+    { Name: "Dili", MeanTemp: 26.6 },
+    { Name: "Djibouti", MeanTemp: 29.9 },
+    { Name: "Dodoma", MeanTemp: 22.7 },
+    { Name: "Dolisie", MeanTemp: 24.0 },
+    { Name: "Douala", MeanTemp: 26.7 },
+    { Name: "Dubai", MeanTemp: 26.9 },
+    { Name: "Dublin", MeanTemp: 9.8 },
+    { Name: "Dunedin", MeanTemp: 11.1 },
+    { Name: "Durban", MeanTemp: 20.6 },
+    { Name: "Dushanbe", MeanTemp: 14.7 },
+    { Name: "Edinburgh", MeanTemp: 9.3 },
+    { Name: "Edmonton", MeanTemp: 4.2 },
+    { Name: "El Paso", MeanTemp: 18.1 },
+    { Name: "Entebbe", MeanTemp: 21.0 },
+    { Name: "Erbil", MeanTemp: 19.5 },
+    { Name: "Erzurum", MeanTemp: 5.1 },
+    { Name: "Fairbanks", MeanTemp: -2.3 },
+    { Name: "Fianarantsoa", MeanTemp: 17.9 },
+    { Name: "Flores,  Petén", MeanTemp: 26.4 },
+    { Name: "Frankfurt", MeanTemp: 10.6 },
+    { Name: "Fresno", MeanTemp: 17.9 },
+    { Name: "Fukuoka", MeanTemp: 17.0 },
+    { Name: "Gabès", MeanTemp: 19.5 },
+    { Name: "Gaborone", MeanTemp: 21.0 },
+    { Name: "Gagnoa", MeanTemp: 26.0 },
+    { Name: "Gangtok", MeanTemp: 15.2 },
+    { Name: "Garissa", MeanTemp: 29.3 },
+    { Name: "Garoua", MeanTemp: 28.3 },
+    { Name: "George Town", MeanTemp: 27.9 },
+    { Name: "Ghanzi", MeanTemp: 21.4 },
+    { Name: "Gjoa Haven", MeanTemp: -14.4 },
+    { Name: "Guadalajara", MeanTemp: 20.9 },
+    { Name: "Guangzhou", MeanTemp: 22.4 },
+    { Name: "Guatemala City", MeanTemp: 20.4 },
+    { Name: "Halifax", MeanTemp: 7.5 },
+    { Name: "Hamburg", MeanTemp: 9.7 },
+    { Name: "Hamilton", MeanTemp: 13.8 },
+    { Name: "Hanga Roa", MeanTemp: 20.5 },
+    { Name: "Hanoi", MeanTemp: 23.6 },
+    { Name: "Harare", MeanTemp: 18.4 },
+    { Name: "Harbin", MeanTemp: 5.0 },
+    { Name: "Hargeisa", MeanTemp: 21.7 },
+    { Name: "Hat Yai", MeanTemp: 27.0 },
+    { Name: "Havana", MeanTemp: 25.2 },
+    { Name: "Helsinki", MeanTemp: 5.9 },
+    { Name: "Heraklion", MeanTemp: 18.9 },
+    { Name: "Hiroshima", MeanTemp: 16.3 },
+    { Name: "Ho Chi Minh City", MeanTemp: 27.4 },
+    { Name: "Hobart", MeanTemp: 12.7 },
+    { Name: "Hong Kong", MeanTemp: 23.3 },
+    { Name: "Honiara", MeanTemp: 26.5 },
+    { Name: "Honolulu", MeanTemp: 25.4 },
+    { Name: "Houston", MeanTemp: 20.8 },
+    { Name: "Ifrane", MeanTemp: 11.4 },
+    { Name: "Indianapolis", MeanTemp: 11.8 },
+    { Name: "Iqaluit", MeanTemp: -9.3 },
+    { Name: "Irkutsk", MeanTemp: 1.0 },
+    { Name: "Istanbul", MeanTemp: 13.9 },
+    { Name: "İzmir", MeanTemp: 17.9 },
+    { Name: "Jacksonville", MeanTemp: 20.3 },
+    { Name: "Jakarta", MeanTemp: 26.7 },
+    { Name: "Jayapura", MeanTemp: 27.0 },
+    { Name: "Jerusalem", MeanTemp: 18.3 },
+    { Name: "Johannesburg", MeanTemp: 15.5 },
+    { Name: "Jos", MeanTemp: 22.8 },
+    { Name: "Juba", MeanTemp: 27.8 },
+    { Name: "Kabul", MeanTemp: 12.1 },
+    { Name: "Kampala", MeanTemp: 20.0 },
+    { Name: "Kandi", MeanTemp: 27.7 },
+    { Name: "Kankan", MeanTemp: 26.5 },
+    { Name: "Kano", MeanTemp: 26.4 },
+    { Name: "Kansas City", MeanTemp: 12.5 },
+    { Name: "Karachi", MeanTemp: 26.0 },
+    { Name: "Karonga", MeanTemp: 24.4 },
+    { Name: "Kathmandu", MeanTemp: 18.3 },
+    { Name: "Khartoum", MeanTemp: 29.9 },
+    { Name: "Kingston", MeanTemp: 27.4 },
+    { Name: "Kinshasa", MeanTemp: 25.3 },
+    { Name: "Kolkata", MeanTemp: 26.7 },
+    { Name: "Kuala Lumpur", MeanTemp: 27.3 },
+    { Name: "Kumasi", MeanTemp: 26.0 },
+    { Name: "Kunming", MeanTemp: 15.7 },
+    { Name: "Kuopio", MeanTemp: 3.4 },
+    { Name: "Kuwait City", MeanTemp: 25.7 },
+    { Name: "Kyiv", MeanTemp: 8.4 },
+    { Name: "Kyoto", MeanTemp: 15.8 },
+    { Name: "La Ceiba", MeanTemp: 26.2 },
+    { Name: "La Paz", MeanTemp: 23.7 },
+    { Name: "Lagos", MeanTemp: 26.8 },
+    { Name: "Lahore", MeanTemp: 24.3 },
+    { Name: "Lake Havasu City", MeanTemp: 23.7 },
+    { Name: "Lake Tekapo", MeanTemp: 8.7 },
+    { Name: "Las Palmas de Gran Canaria", MeanTemp: 21.2 },
+    { Name: "Las Vegas", MeanTemp: 20.3 },
+    { Name: "Launceston", MeanTemp: 13.1 },
+    { Name: "Lhasa", MeanTemp: 7.6 },
+    { Name: "Libreville", MeanTemp: 25.9 },
+    { Name: "Lisbon", MeanTemp: 17.5 },
+    { Name: "Livingstone", MeanTemp: 21.8 },
+    { Name: "Ljubljana", MeanTemp: 10.9 },
+    { Name: "Lodwar", MeanTemp: 29.3 },
+    { Name: "Lomé", MeanTemp: 26.9 },
+    { Name: "London", MeanTemp: 11.3 },
+    { Name: "Los Angeles", MeanTemp: 18.6 },
+    { Name: "Louisville", MeanTemp: 13.9 },
+    { Name: "Luanda", MeanTemp: 25.8 },
+    { Name: "Lubumbashi", MeanTemp: 20.8 },
+    { Name: "Lusaka", MeanTemp: 19.9 },
+    { Name: "Luxembourg City", MeanTemp: 9.3 },
+    { Name: "Lviv", MeanTemp: 7.8 },
+    { Name: "Lyon", MeanTemp: 12.5 },
+    { Name: "Madrid", MeanTemp: 15.0 },
+    { Name: "Mahajanga", MeanTemp: 26.3 },
+    { Name: "Makassar", MeanTemp: 26.7 },
+    { Name: "Makurdi", MeanTemp: 26.0 },
+    { Name: "Malabo", MeanTemp: 26.3 },
+    { Name: "Malé", MeanTemp: 28.0 },
+    { Name: "Managua", MeanTemp: 27.3 },
+    { Name: "Manama", MeanTemp: 26.5 },
+    { Name: "Mandalay", MeanTemp: 28.0 },
+    { Name: "Mango", MeanTemp: 28.1 },
+    { Name: "Manila", MeanTemp: 28.4 },
+    { Name: "Maputo", MeanTemp: 22.8 },
+    { Name: "Marrakesh", MeanTemp: 19.6 },
+    { Name: "Marseille", MeanTemp: 15.8 },
+    { Name: "Maun", MeanTemp: 22.4 },
+    { Name: "Medan", MeanTemp: 26.5 },
+    { Name: "Mek'ele", MeanTemp: 22.7 },
+    { Name: "Melbourne", MeanTemp: 15.1 },
+    { Name: "Memphis", MeanTemp: 17.2 },
+    { Name: "Mexicali", MeanTemp: 23.1 },
+    { Name: "Mexico City", MeanTemp: 17.5 },
+    { Name: "Miami", MeanTemp: 24.9 },
+    { Name: "Milan", MeanTemp: 13.0 },
+    { Name: "Milwaukee", MeanTemp: 8.9 },
+    { Name: "Minneapolis", MeanTemp: 7.8 },
+    { Name: "Minsk", MeanTemp: 6.7 },
+    { Name: "Mogadishu", MeanTemp: 27.1 },
+    { Name: "Mombasa", MeanTemp: 26.3 },
+    { Name: "Monaco", MeanTemp: 16.4 },
+    { Name: "Moncton", MeanTemp: 6.1 },
+    { Name: "Monterrey", MeanTemp: 22.3 },
+    { Name: "Montreal", MeanTemp: 6.8 },
+    { Name: "Moscow", MeanTemp: 5.8 },
+    { Name: "Mumbai", MeanTemp: 27.1 },
+    { Name: "Murmansk", MeanTemp: 0.6 },
+    { Name: "Muscat", MeanTemp: 28.0 },
+    { Name: "Mzuzu", MeanTemp: 17.7 },
+    { Name: "N'Djamena", MeanTemp: 28.3 },
+    { Name: "Naha", MeanTemp: 23.1 },
+    { Name: "Nairobi", MeanTemp: 17.8 },
+    { Name: "Nakhon Ratchasima", MeanTemp: 27.3 },
+    { Name: "Napier", MeanTemp: 14.6 },
+    { Name: "Napoli", MeanTemp: 15.9 },
+    { Name: "Nashville", MeanTemp: 15.4 },
+    { Name: "Nassau", MeanTemp: 24.6 },
+    { Name: "Ndola", MeanTemp: 20.3 },
+    { Name: "New Delhi", MeanTemp: 25.0 },
+    { Name: "New Orleans", MeanTemp: 20.7 },
+    { Name: "New York City", MeanTemp: 12.9 },
+    { Name: "Ngaoundéré", MeanTemp: 22.0 },
+    { Name: "Niamey", MeanTemp: 29.3 },
+    { Name: "Nicosia", MeanTemp: 19.7 },
+    { Name: "Niigata", MeanTemp: 13.9 },
+    { Name: "Nouadhibou", MeanTemp: 21.3 },
+    { Name: "Nouakchott", MeanTemp: 25.7 },
+    { Name: "Novosibirsk", MeanTemp: 1.7 },
+    { Name: "Nuuk", MeanTemp: -1.4 },
+    { Name: "Odesa", MeanTemp: 10.7 },
+    { Name: "Odienné", MeanTemp: 26.0 },
+    { Name: "Oklahoma City", MeanTemp: 15.9 },
+    { Name: "Omaha", MeanTemp: 10.6 },
+    { Name: "Oranjestad", MeanTemp: 28.1 },
+    { Name: "Oslo", MeanTemp: 5.7 },
+    { Name: "Ottawa", MeanTemp: 6.6 },
+    { Name: "Ouagadougou", MeanTemp: 28.3 },
+    { Name: "Ouahigouya", MeanTemp: 28.6 },
+    { Name: "Ouarzazate", MeanTemp: 18.9 },
+    { Name: "Oulu", MeanTemp: 2.7 },
+    { Name: "Palembang", MeanTemp: 27.3 },
+    { Name: "Palermo", MeanTemp: 18.5 },
+    { Name: "Palm Springs", MeanTemp: 24.5 },
+    { Name: "Palmerston North", MeanTemp: 13.2 },
+    { Name: "Panama City", MeanTemp: 28.0 },
+    { Name: "Parakou", MeanTemp: 26.8 },
+    { Name: "Paris", MeanTemp: 12.3 },
+    { Name: "Perth", MeanTemp: 18.7 },
+    { Name: "Petropavlovsk-Kamchatsky", MeanTemp: 1.9 },
+    { Name: "Philadelphia", MeanTemp: 13.2 },
+    { Name: "Phnom Penh", MeanTemp: 28.3 },
+    { Name: "Phoenix", MeanTemp: 23.9 },
+    { Name: "Pittsburgh", MeanTemp: 10.8 },
+    { Name: "Podgorica", MeanTemp: 15.3 },
+    { Name: "Pointe-Noire", MeanTemp: 26.1 },
+    { Name: "Pontianak", MeanTemp: 27.7 },
+    { Name: "Port Moresby", MeanTemp: 26.9 },
+    { Name: "Port Sudan", MeanTemp: 28.4 },
+    { Name: "Port Vila", MeanTemp: 24.3 },
+    { Name: "Port-Gentil", MeanTemp: 26.0 },
+    { Name: "Portland (OR)", MeanTemp: 12.4 },
+    { Name: "Porto", MeanTemp: 15.7 },
+    { Name: "Prague", MeanTemp: 8.4 },
+    { Name: "Praia", MeanTemp: 24.4 },
+    { Name: "Pretoria", MeanTemp: 18.2 },
+    { Name: "Pyongyang", MeanTemp: 10.8 },
+    { Name: "Rabat", MeanTemp: 17.2 },
+    { Name: "Rangpur", MeanTemp: 24.4 },
+    { Name: "Reggane", MeanTemp: 28.3 },
+    { Name: "Reykjavík", MeanTemp: 4.3 },
+    { Name: "Riga", MeanTemp: 6.2 },
+    { Name: "Riyadh", MeanTemp: 26.0 },
+    { Name: "Rome", MeanTemp: 15.2 },
+    { Name: "Roseau", MeanTemp: 26.2 },
+    { Name: "Rostov-on-Don", MeanTemp: 9.9 },
+    { Name: "Sacramento", MeanTemp: 16.3 },
+    { Name: "Saint Petersburg", MeanTemp: 5.8 },
+    { Name: "Saint-Pierre", MeanTemp: 5.7 },
+    { Name: "Salt Lake City", MeanTemp: 11.6 },
+    { Name: "San Antonio", MeanTemp: 20.8 },
+    { Name: "San Diego", MeanTemp: 17.8 },
+    { Name: "San Francisco", MeanTemp: 14.6 },
+    { Name: "San Jose", MeanTemp: 16.4 },
+    { Name: "San José", MeanTemp: 22.6 },
+    { Name: "San Juan", MeanTemp: 27.2 },
+    { Name: "San Salvador", MeanTemp: 23.1 },
+    { Name: "Sana'a", MeanTemp: 20.0 },
+    { Name: "Santo Domingo", MeanTemp: 25.9 },
+    { Name: "Sapporo", MeanTemp: 8.9 },
+    { Name: "Sarajevo", MeanTemp: 10.1 },
+    { Name: "Saskatoon", MeanTemp: 3.3 },
+    { Name: "Seattle", MeanTemp: 11.3 },
+    { Name: "Ségou", MeanTemp: 28.0 },
+    { Name: "Seoul", MeanTemp: 12.5 },
+    { Name: "Seville", MeanTemp: 19.2 },
+    { Name: "Shanghai", MeanTemp: 16.7 },
+    { Name: "Singapore", MeanTemp: 27.0 },
+    { Name: "Skopje", MeanTemp: 12.4 },
+    { Name: "Sochi", MeanTemp: 14.2 },
+    { Name: "Sofia", MeanTemp: 10.6 },
+    { Name: "Sokoto", MeanTemp: 28.0 },
+    { Name: "Split", MeanTemp: 16.1 },
+    { Name: "St. John's", MeanTemp: 5.0 },
+    { Name: "St. Louis", MeanTemp: 13.9 },
+    { Name: "Stockholm", MeanTemp: 6.6 },
+    { Name: "Surabaya", MeanTemp: 27.1 },
+    { Name: "Suva", MeanTemp: 25.6 },
+    { Name: "Suwałki", MeanTemp: 7.2 },
+    { Name: "Sydney", MeanTemp: 17.7 },
+    { Name: "Tabora", MeanTemp: 23.0 },
+    { Name: "Tabriz", MeanTemp: 12.6 },
+    { Name: "Taipei", MeanTemp: 23.0 },
+    { Name: "Tallinn", MeanTemp: 6.4 },
+    { Name: "Tamale", MeanTemp: 27.9 },
+    { Name: "Tamanrasset", MeanTemp: 21.7 },
+    { Name: "Tampa", MeanTemp: 22.9 },
+    { Name: "Tashkent", MeanTemp: 14.8 },
+    { Name: "Tauranga", MeanTemp: 14.8 },
+    { Name: "Tbilisi", MeanTemp: 12.9 },
+    { Name: "Tegucigalpa", MeanTemp: 21.7 },
+    { Name: "Tehran", MeanTemp: 17.0 },
+    { Name: "Tel Aviv", MeanTemp: 20.0 },
+    { Name: "Thessaloniki", MeanTemp: 16.0 },
+    { Name: "Thiès", MeanTemp: 24.0 },
+    { Name: "Tijuana", MeanTemp: 17.8 },
+    { Name: "Timbuktu", MeanTemp: 28.0 },
+    { Name: "Tirana", MeanTemp: 15.2 },
+    { Name: "Toamasina", MeanTemp: 23.4 },
+    { Name: "Tokyo", MeanTemp: 15.4 },
+    { Name: "Toliara", MeanTemp: 24.1 },
+    { Name: "Toluca", MeanTemp: 12.4 },
+    { Name: "Toronto", MeanTemp: 9.4 },
+    { Name: "Tripoli", MeanTemp: 20.0 },
+    { Name: "Tromsø", MeanTemp: 2.9 },
+    { Name: "Tucson", MeanTemp: 20.9 },
+    { Name: "Tunis", MeanTemp: 18.4 },
+    { Name: "Ulaanbaatar", MeanTemp: -0.4 },
+    { Name: "Upington", MeanTemp: 20.4 },
+    { Name: "Ürümqi", MeanTemp: 7.4 },
+    { Name: "Vaduz", MeanTemp: 10.1 },
+    { Name: "Valencia", MeanTemp: 18.3 },
+    { Name: "Valletta", MeanTemp: 18.8 },
+    { Name: "Vancouver", MeanTemp: 10.4 },
+    { Name: "Veracruz", MeanTemp: 25.4 },
+    { Name: "Vienna", MeanTemp: 10.4 },
+    { Name: "Vientiane", MeanTemp: 25.9 },
+    { Name: "Villahermosa", MeanTemp: 27.1 },
+    { Name: "Vilnius", MeanTemp: 6.0 },
+    { Name: "Virginia Beach", MeanTemp: 15.8 },
+    { Name: "Vladivostok", MeanTemp: 4.9 },
+    { Name: "Warsaw", MeanTemp: 8.5 },
+    { Name: "Washington, D.C.", MeanTemp: 14.6 },
+    { Name: "Wau", MeanTemp: 27.8 },
+    { Name: "Wellington", MeanTemp: 12.9 },
+    { Name: "Whitehorse", MeanTemp: -0.1 },
+    { Name: "Wichita", MeanTemp: 13.9 },
+    { Name: "Willemstad", MeanTemp: 28.0 },
+    { Name: "Winnipeg", MeanTemp: 3.0 },
+    { Name: "Wrocław", MeanTemp: 9.6 },
+    { Name: "Xi'an", MeanTemp: 14.1 },
+    { Name: "Yakutsk", MeanTemp: -8.8 },
+    { Name: "Yangon", MeanTemp: 27.5 },
+    { Name: "Yaoundé", MeanTemp: 23.8 },
+    { Name: "Yellowknife", MeanTemp: -4.3 },
+    { Name: "Yerevan", MeanTemp: 12.4 },
+    { Name: "Yinchuan", MeanTemp: 9.0 },
+    { Name: "Zagreb", MeanTemp: 10.7 },
+    { Name: "Zanzibar City", MeanTemp: 26.0 },
+    { Name: "Zürich", MeanTemp: 9.3 },
+}
@@ -0,0 +1,93 @@
+package generator
+
+import (
+    "fmt"
+    "math/rand/v2"
+    "slices"
+    "strings"
+)
+
+// DefectKinds are the ways a line can be corrupted
+var DefectKinds = []string{
+    "separator",   // the ';' is missing
+    "temperature", // a digit of the temperature is a letter
+    "decimals",    // the temperature has two decimal digits
+    "truncated",   // the line is cut short
+    "cr",          // a '\r' is inserted in the temperature
+    "nul",         // a NUL byte is inserted in or after the temperature
+    "utf8",        // the name is not valid UTF-8
+}
+
+// Corruption describes the defects injected in the measurements, to test
+// how the parsers handle dirty data. The kinds implement flag.Value, as a
+// comma separated list of DefectKinds
+type Corruption struct {
+    Fraction float64  // fraction of the lines with a defect
+    Kinds    []string // drawn uniformly for every corrupted line
+}
+
+func (c *Corruption) String() string {
+    return strings.Join(c.Kinds, ",")
+}
+
+func (c *Corruption) Set(value string) error {
+    var kinds []string
+    for _, kind := range strings.Split(value, ",") {
+        kind = strings.TrimSpace(kind)
+        if !slices.Contains(DefectKinds, kind) {
+            return fmt.Errorf("unknown defect %q", kind)
+        }
+        kinds = append(kinds, kind)
+    }
+    c.Kinds = kinds
+    return nil
+}
+
+// defect is a corrupted line of a chunk
+type defect struct {
+    offset int // of the start of the line in the chunk
+    line   int // index of the line in the chunk
+    kind   string
+}
+
+// corrupt appends to buf the line of name and temp, without its '\n',
+// with a defect of the given kind
+func corrupt(buf []byte, r *rand.Rand, kind string, name string, temp string) []byte {
+    line := name + ";" + temp
+
+    switch kind {
+    case "separator":
+        return append(append(buf, name...), temp...)
+    case "temperature":
+        t := []byte(temp)
+        for {
+            i := r.IntN(len(t))
+            if t[i] >= '0' && t[i] <= '9' {
+                t[i] = byte('a' + r.IntN(26))
+                break
+            }
+        }
+        return append(append(append(buf, name...), ';'), t...)
+    case "decimals":
+        return append(append(buf, line...), byte('0' + r.IntN(10)))
+    case "truncated":
+        return append(buf, line[:1 + r.IntN(len(line) - 1)]...)
+    // in the name, a '\r' or a NUL would only make an odd but valid
+    // station, so they go after the ';'
+    case "cr":
+        return insert(buf, line, len(name) + 1 + r.IntN(len(temp)), '\r')
+    case "nul":
+        return insert(buf, line, len(name) + 1 + r.IntN(len(temp) + 1), 0)
+    case "utf8":
+        return insert(buf, line, r.IntN(len(name) + 1), 0xFF)
+    default:
+        panic("unknown defect " + kind)
+    }
+}
+
+// insert appends to buf the line with c inserted at i
+func insert(buf []byte, line string, i int, c byte) []byte {
+    buf = append(buf, line[:i]...)
+    buf = append(buf, c)
+    return append(buf, line[i:]...)
+}
@@ -0,0 +1,103 @@
+package generator
+
+import (
+    "bytes"
+    "math/rand/v2"
+    "strconv"
+    "strings"
+    "testing"
+    "unicode/utf8"
+)
+
+// validLine reports whether line, without its '\n', is a measurement the
+// challenge allows
+func validLine(line string) bool {
+    name, temp, found := strings.Cut(line, ";")
+    if !found || len(name) == 0 || len(name) > MAX_NAME_LENGTH {
+        return false
+    }
+    if !utf8.ValidString(name) {
+        return false
+    }
+    _, err := ParseTenths(temp)
+    return err == nil
+}
+
+func TestCorrupt(t *testing.T) {
+    r := rand.New(rand.NewPCG(1, 2))
+
+    for _, kind := range DefectKinds {
+        for _, ws := range BuiltinStations[:50] {
+            temp := FormatTenths(MIN_TENTHS + r.Int64N(MAX_TENTHS - MIN_TENTHS + 1))
+            line := string(corrupt(nil, r, kind, ws.Name, temp))
+
+            if validLine(line) {
+                t.Errorf("%s: %q is valid", kind, line)
+            }
+            if strings.Contains(line, "\n") || strings.HasSuffix(line, "\r") {
+                t.Errorf("%s: %q ends early", kind, line)
+            }
+        }
+    }
+}
+
+func TestGenerateCorruption(t *testing.T) {
+    g := New(42, BUFFERED_LINES + 1000)
+    g.Values.Corrupt.Fraction = 0.01
+    g.Workers = 3
+
+    var data, defects bytes.Buffer
+    g.Defects = &defects
+    _, err := g.WriteTo(&data)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    lines := strings.SplitAfter(data.String(), "\n")
+    lines = lines[:len(lines)-1]
+    if len(lines) != BUFFERED_LINES + 1000 {
+        t.Fatalf("got %d lines", len(lines))
+    }
+
+    offsets := make([]int, len(lines))
+    for i := 1; i < len(lines); i++ {
+        offsets[i] = offsets[i-1] + len(lines[i-1])
+    }
+
+    // every defect is where it is recorded, and every other line is valid
+    corrupted := make(map[int]bool)
+    for _, record := range strings.Split(strings.TrimSpace(defects.String()), "\n") {
+        fields := strings.Split(record, "\t")
+        offset, _ := strconv.Atoi(fields[0])
+        line, _ := strconv.Atoi(fields[1])
+
+        if offsets[line-1] != offset {
+            t.Errorf("line %d: recorded at offset %d instead of %d", line, offset, offsets[line-1])
+        }
+        corrupted[line-1] = true
+    }
+    if int64(len(corrupted)) != g.Stats().Defects || len(corrupted) < 1000 {
+        t.Fatalf("%d defects recorded, %d counted", len(corrupted), g.Stats().Defects)
+    }
+
+    var clean strings.Builder
+    for i, line := range lines {
+        if validLine(strings.TrimSuffix(line, "\n")) == corrupted[i] {
+            t.Errorf("line %d: %q corrupted=%v", i+1, line, corrupted[i])
+        }
+        if !corrupted[i] {
+            clean.WriteString(line)
+        }
+    }
+
+    // the result leaves the corrupted lines out
+    var got bytes.Buffer
+    err = g.Result(&got)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if diff := diffResults(got.String(), reference(t, clean.String())); diff != "" {
+        t.Error(diff)
+    }
+}
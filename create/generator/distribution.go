@@ -0,0 +1,183 @@
+package generator
+
+import (
+    "fmt"
+    "math"
+    "math/rand/v2"
+    "strconv"
+    "strings"
+)
+
+// MIN_TEMP and MAX_TEMP are the bounds of the measurements allowed by
+// the challenge, MIN_TENTHS and MAX_TENTHS the same in tenths of a degree
+const (
+    MIN_TEMP = -99.9
+    MAX_TEMP = 99.9
+
+    MIN_TENTHS = -999
+    MAX_TENTHS = 999
+)
+
+// Values holds the distributions used to draw the measurements: which
+// station a line is about and the temperature measured there
+type Values struct {
+    Popularity Popularity
+    Spread     Spread
+    Outliers   float64 // fraction of values drawn uniformly from the whole range
+    Clamp      bool    // keep the values between MIN_TEMP and MAX_TEMP
+    Corrupt    Corruption
+}
+
+// DefaultValues are the distributions of the original challenge
+func DefaultValues() Values {
+    return Values{
+        Popularity: Popularity{ kind: "uniform" },
+        Spread: Spread{ kind: "normal", a: 10 },
+        Clamp: true,
+        Corrupt: Corruption{ Kinds: DefectKinds },
+    }
+}
+
+// picker returns a function drawing station indexes between 0 and n-1
+// from r
+func (v *Values) picker(r *rand.Rand, n int) func() int {
+    switch v.Popularity.kind {
+    case "zipf":
+        z := rand.NewZipf(r, v.Popularity.a, 1, uint64(n-1))
+        return func() int { return int(z.Uint64()) }
+    case "hot":
+        return func() int {
+            if n == 1 || r.Float64() < v.Popularity.a {
+                return 0
+            }
+            return 1 + r.IntN(n - 1)
+        }
+    default:
+        return func() int { return r.IntN(n) }
+    }
+}
+
+// measurement draws a temperature of ws from r, in tenths of a degree
+func (v *Values) measurement(r *rand.Rand, ws Station) int64 {
+    var m float64
+    if v.Outliers > 0 && r.Float64() < v.Outliers {
+        m = MIN_TEMP + r.Float64() * (MAX_TEMP - MIN_TEMP)
+    } else {
+        m = v.Spread.sample(r, ws.MeanTemp)
+    }
+
+    t := int64(math.Round(m * 10.0))
+    if v.Clamp {
+        t = min(max(t, MIN_TENTHS), MAX_TENTHS)
+    }
+    return t
+}
+
+// Popularity is the distribution of the stations among the lines. It
+// implements flag.Value and accepts "uniform", "zipf:S" with S > 1, where
+// the first stations are the most frequent, and "hot:P", where the first
+// station is in a fraction P of the lines and the others share the rest
+// uniformly
+type Popularity struct {
+    kind string
+    a    float64
+}
+
+func (d *Popularity) String() string {
+    switch d.kind {
+    case "zipf", "hot":
+        return fmt.Sprintf("%s:%g", d.kind, d.a)
+    default:
+        return d.kind
+    }
+}
+
+func (d *Popularity) Set(value string) error {
+    kind, params, _ := strings.Cut(value, ":")
+    var err error
+
+    switch kind {
+    case "uniform":
+    case "zipf":
+        d.a, err = strconv.ParseFloat(params, 64)
+        if err == nil && d.a <= 1 {
+            err = fmt.Errorf("zipf exponent must be greater than 1, got %g", d.a)
+        }
+    case "hot":
+        d.a, err = strconv.ParseFloat(params, 64)
+        if err == nil && (d.a < 0 || d.a > 1) {
+            err = fmt.Errorf("hot fraction must be between 0 and 1, got %g", d.a)
+        }
+    default:
+        err = fmt.Errorf("unknown popularity distribution %q", kind)
+    }
+
+    if err != nil {
+        return err
+    }
+    d.kind = kind
+    return nil
+}
+
+// Spread is the distribution of the temperatures of a station around
+// its mean. It implements flag.Value and accepts "normal:SIGMA",
+// "uniform:WIDTH", for values within mean ± WIDTH, and
+// "bimodal:OFFSET,SIGMA", for two normal distributions centered in
+// mean ± OFFSET
+type Spread struct {
+    kind string
+    a, b float64
+}
+
+func (d *Spread) String() string {
+    switch d.kind {
+    case "normal", "uniform":
+        return fmt.Sprintf("%s:%g", d.kind, d.a)
+    case "bimodal":
+        return fmt.Sprintf("bimodal:%g,%g", d.a, d.b)
+    default:
+        return ""
+    }
+}
+
+func (d *Spread) Set(value string) error {
+    kind, params, _ := strings.Cut(value, ":")
+    var err error
+
+    switch kind {
+    case "normal", "uniform":
+        d.a, err = strconv.ParseFloat(params, 64)
+        if err == nil && d.a < 0 {
+            err = fmt.Errorf("%s spread must not be negative, got %g", kind, d.a)
+        }
+    case "bimodal":
+        d.a, d.b, err = parsePair(params, ",")
+        if err == nil && d.b < 0 {
+            err = fmt.Errorf("bimodal sigma must not be negative, got %g", d.b)
+        }
+    default:
+        err = fmt.Errorf("unknown temperature spread %q", kind)
+    }
+
+    if err != nil {
+        return err
+    }
+    d.kind = kind
+    return nil
+}
+
+func (d *Spread) sample(r *rand.Rand, mean float64) float64 {
+    switch d.kind {
+    case "uniform":
+        return mean + (r.Float64() * 2 - 1) * d.a
+    case "bimodal":
+        if r.IntN(2) == 0 {
+            mean -= d.a
+        } else {
+            mean += d.a
+        }
+        return r.NormFloat64() * d.b + mean
+    default:
+        return r.NormFloat64() * d.a + mean
+    }
+}
@@ -0,0 +1,40 @@
+package generator_test
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+
+    "create/generator"
+)
+
+// The measurements go straight from the generator to the code reading
+// them, without a file in between
+func ExampleGenerator_WriteTo() {
+    g := generator.New(42, 1000)
+
+    r, w := io.Pipe()
+    go func() {
+        _, err := g.WriteTo(w)
+        w.CloseWithError(err)
+    }()
+
+    lines := 0
+    sc := bufio.NewScanner(r)
+    for sc.Scan() {
+        lines++
+    }
+    fmt.Println(lines, "lines")
+
+    // the result is known as soon as the measurements are written
+    g.Stations = []generator.Station{ { Name: "Lisbon", MeanTemp: 17.5 } }
+    g.Target = generator.Target{ Rows: 2 }
+    g.WriteTo(io.Discard)
+    g.Result(os.Stdout)
+    // Output:
+    // 1000 lines
+    // {
+    // 	Lisbon=16.8/23.1/29.3
+    // }
+}
@@ -0,0 +1,224 @@
+package generator
+
+import (
+    "bytes"
+    "compress/gzip"
+    "math"
+    "math/rand/v2"
+    "sync"
+    "sync/atomic"
+)
+
+// BUFFERED_LINES is the number of lines of a chunk, the unit of work of
+// the generator
+const BUFFERED_LINES = 2048 * 64
+
+// STATIONS_STREAM is the random stream used to synthesise the stations,
+// which must not be the one of any chunk
+const STATIONS_STREAM = math.MaxUint64
+
+// GZIP_LEVEL favours speed, as the generator would otherwise be limited
+// by the compression
+const GZIP_LEVEL = gzip.BestSpeed
+
+// PIPELINE_DEPTH is the number of chunks of every worker, so that it can
+// generate the next one while the previous ones wait to be written
+const PIPELINE_DEPTH = 3
+
+// chunk is a block of consecutive lines, identified by its position in
+// the file. Its buffers belong to a worker and are reused once written
+type chunk struct {
+    index   int
+    lines   int
+    data    []byte
+    gz      []byte // data as a gzip member, if the output is compressed
+    written int    // lines actually written, set by the output
+    defects []defect
+
+    // the station and the value of every line, which are added to the
+    // aggregates of the worker only once the output knows how many lines
+    // were written. Corrupted lines have no station
+    picks []int32
+    temps []int32
+
+    owner chan *chunk // where the chunk goes back after being written
+}
+
+// chunkRand returns the random stream of the chunk at the given index
+func chunkRand(seed uint64, index int) *rand.Rand {
+    return rand.New(rand.NewPCG(seed, uint64(index)))
+}
+
+// generate writes measurements of stations, drawn from the given
+// distributions, to out until its target is reached. Every chunk draws
+// from its own random stream derived from seed and the chunks are written
+// in order, so the output only depends on the arguments and not on the
+// number of workers generating them.
+// It returns the aggregates of the values actually written, indexed like
+// stations: each worker keeps its own, which are merged at the end
+func generate(out *output, stations []Station, values *Values, seed uint64, workers int) ([]Aggregate, error) {
+    // without a number of lines, chunks are generated until the output
+    // reaches its size
+    nChunks := math.MaxInt
+    if out.target.Bytes == 0 {
+        nChunks = int((out.target.Rows + BUFFERED_LINES - 1) / BUFFERED_LINES)
+    }
+
+    var nextChunk atomic.Int64
+    var stop atomic.Bool
+
+    results := make(chan *chunk, workers * PIPELINE_DEPTH)
+    partials := make([][]Aggregate, workers)
+    var wg sync.WaitGroup
+    wg.Add(workers)
+
+    go func() {
+        wg.Wait()
+        close(results)
+    }()
+
+    for w := range workers {
+        go func() {
+            defer wg.Done()
+
+            aggregates := make([]Aggregate, len(stations))
+            partials[w] = aggregates
+
+            // the chunks come back here once written, which also limits
+            // how far ahead of the output a worker can go
+            free := make(chan *chunk, PIPELINE_DEPTH)
+            for range PIPELINE_DEPTH {
+                free <- &chunk{
+                    data: make([]byte, 0, 16 * BUFFERED_LINES),
+                    picks: make([]int32, BUFFERED_LINES),
+                    temps: make([]int32, BUFFERED_LINES),
+                    owner: free,
+                }
+            }
+
+            var zbuf bytes.Buffer
+            var zw *gzip.Writer
+            if out.gzip {
+                zw, _ = gzip.NewWriterLevel(&zbuf, GZIP_LEVEL)
+            }
+
+            for {
+                // the index is taken only with a free chunk and if the
+                // output is not done, as it waits for every index taken
+                c := <-free
+                addWritten(aggregates, c)
+
+                index := -1
+                if !stop.Load() {
+                    index = int(nextChunk.Add(1)) - 1
+                }
+                if index < 0 || index >= nChunks {
+                    // the other chunks are free or will be once written
+                    for range PIPELINE_DEPTH - 1 {
+                        addWritten(aggregates, <-free)
+                    }
+                    return
+                }
+
+                c.index = index
+                c.lines = BUFFERED_LINES
+                if out.target.Bytes == 0 {
+                    c.lines = int(min(BUFFERED_LINES, out.target.Rows - int64(index) * BUFFERED_LINES))
+                }
+                fillChunk(c, chunkRand(seed, index), stations, values)
+
+                if zw != nil {
+                    zbuf.Reset()
+                    zw.Reset(&zbuf)
+                    zw.Write(c.data)
+                    zw.Close()
+                    c.gz = append(c.gz[:0], zbuf.Bytes()...)
+                }
+
+                results <- c
+            }
+        }()
+    }
+
+    // chunks arriving before their turn are kept until the previous ones
+    // are written, and every chunk goes back to its worker afterwards
+    var err error
+    pending := make(map[int]*chunk, workers * PIPELINE_DEPTH)
+    next := 0
+
+    for c := range results {
+        pending[c.index] = c
+
+        for {
+            p, ok := pending[next]
+            if !ok {
+                break
+            }
+            delete(pending, next)
+            next++
+
+            if err == nil && !stop.Load() {
+                var reached bool
+                reached, err = out.write(p)
+                if reached || err != nil {
+                    stop.Store(true)
+                }
+            }
+            p.owner <- p
+        }
+    }
+
+    if err != nil {
+        return nil, err
+    }
+
+    aggregates := partials[0]
+    for _, partial := range partials[1:] {
+        for i := range aggregates {
+            aggregates[i].Merge(partial[i])
+        }
+    }
+    return aggregates, out.finish()
+}
+
+// fillChunk writes c.lines lines drawn from r into c
+func fillChunk(c *chunk, r *rand.Rand, stations []Station, values *Values) {
+    pick := values.picker(r, len(stations))
+    buf := c.data[:0]
+    c.defects = c.defects[:0]
+    c.written = 0
+
+    for i := range c.lines {
+        index := pick()
+        station := stations[index]
+        t := values.measurement(r, station)
+        c.picks[i], c.temps[i] = int32(index), int32(t)
+
+        if values.Corrupt.Fraction > 0 && r.Float64() < values.Corrupt.Fraction {
+            kind := values.Corrupt.Kinds[r.IntN(len(values.Corrupt.Kinds))]
+            c.defects = append(c.defects, defect{ offset: len(buf), line: i, kind: kind })
+            c.picks[i] = -1
+
+            buf = corrupt(buf, r, kind, station.Name, FormatTenths(t))
+            buf = append(buf, '\n')
+            continue
+        }
+
+        buf = append(buf, station.Name...)
+        buf = append(buf, ';')
+        buf = AppendTenths(buf, t)
+        buf = append(buf, '\n')
+    }
+
+    c.data = buf
+}
+
+// addWritten adds the lines of c that were written to aggregates
+func addWritten(aggregates []Aggregate, c *chunk) {
+    for i := range c.written {
+        if c.picks[i] >= 0 {
+            aggregates[c.picks[i]].Add(int64(c.temps[i]))
+        }
+    }
+    c.written = 0
+}
@@ -0,0 +1,260 @@
+package generator
+
+import (
+    "bytes"
+    "compress/gzip"
+    "crypto/sha256"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+func TestGenerateIsReproducible(t *testing.T) {
+    // not a multiple of BUFFERED_LINES, so the last chunk is shorter
+    size := BUFFERED_LINES * 3 + 17
+
+    var want bytes.Buffer
+    g := New(42, int64(size))
+    g.Workers = 1
+    _, err := g.WriteTo(&want)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if lines := bytes.Count(want.Bytes(), []byte("\n")); lines != size {
+        t.Fatalf("got %d lines, want %d", lines, size)
+    }
+
+    for _, workers := range []int{2, 3, 8} {
+        var got bytes.Buffer
+        g.Workers = workers
+        _, err := g.WriteTo(&got)
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        if !bytes.Equal(got.Bytes(), want.Bytes()) {
+            t.Errorf("%d workers generated a different file than a single one", workers)
+        }
+    }
+
+    var other bytes.Buffer
+    g.Seed = 43
+    _, err = g.WriteTo(&other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if bytes.Equal(other.Bytes(), want.Bytes()) {
+        t.Error("different seeds generated the same file")
+    }
+}
+
+func TestGenerateRowCount(t *testing.T) {
+    for _, size := range []int{ 0, 1, BUFFERED_LINES - 1, BUFFERED_LINES, BUFFERED_LINES + 1, BUFFERED_LINES * 3 + 17 } {
+        // more workers than chunks too
+        for _, workers := range []int{ 1, 4, 16 } {
+            var buf bytes.Buffer
+            g := New(42, int64(size))
+            g.Workers = workers
+            n, err := g.WriteTo(&buf)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            var counted int
+            for _, a := range g.Aggregates() {
+                counted += a.Count
+            }
+
+            lines := bytes.Count(buf.Bytes(), []byte("\n"))
+            stats := g.Stats()
+            if lines != size || stats.Rows != int64(size) || counted != size {
+                t.Errorf("size=%d workers=%d: got %d lines, %d written, %d aggregated", size, workers, lines, stats.Rows, counted)
+            }
+            if n != int64(buf.Len()) || stats.Bytes != n {
+                t.Errorf("size=%d workers=%d: got %d bytes, %d returned, %d counted", size, workers, buf.Len(), n, stats.Bytes)
+            }
+        }
+    }
+}
+
+func TestGenerateOutputs(t *testing.T) {
+    var want bytes.Buffer
+    _, err := New(42, BUFFERED_LINES * 2).WriteTo(&want)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // a size in the middle of a line, which is left out
+    size := int64(want.Len() * 3 / 4)
+    whole := int64(bytes.LastIndexByte(want.Bytes()[:size], '\n') + 1)
+
+    // fewer lines than shards leave some of them empty
+    lines := bytes.SplitAfter(want.Bytes(), []byte("\n"))
+    two := len(lines[0]) + len(lines[1])
+
+    for _, tt := range []struct {
+        name     string
+        target   Target
+        shards   int
+        compress bool
+        want     []byte
+    }{
+        { "rows", Target{ Rows: BUFFERED_LINES * 2 }, 1, false, want.Bytes() },
+        { "bytes", Target{ Bytes: size }, 1, false, want.Bytes()[:whole] },
+        { "gzip", Target{ Bytes: size }, 1, true, want.Bytes()[:whole] },
+        { "shards", Target{ Rows: BUFFERED_LINES * 2 }, 3, false, want.Bytes() },
+        { "gzip shards", Target{ Bytes: size }, 5, true, want.Bytes()[:whole] },
+        { "empty shards", Target{ Rows: 2 }, 4, true, want.Bytes()[:two] },
+    } {
+        t.Run(tt.name, func(t *testing.T) {
+            bufs := make([]bytes.Buffer, tt.shards)
+            shards := make([]io.Writer, tt.shards)
+            for i := range bufs {
+                shards[i] = &bufs[i]
+            }
+
+            g := New(42, 0)
+            g.Target = tt.target
+            g.Gzip = tt.compress
+            g.Workers = 3
+            err := g.WriteShards(shards)
+            if err != nil {
+                t.Fatal(err)
+            }
+            stats := g.Stats()
+
+            var got []byte
+            for i := range bufs {
+                if sum := sha256.Sum256(bufs[i].Bytes()); !bytes.Equal(stats.Shards[i].SHA256, sum[:]) {
+                    t.Errorf("wrong SHA-256 of shard %d", i)
+                }
+                if stats.Shards[i].Bytes != int64(bufs[i].Len()) {
+                    t.Errorf("counted %d bytes in shard %d, want %d", stats.Shards[i].Bytes, i, bufs[i].Len())
+                }
+
+                data := bufs[i].Bytes()
+                if tt.compress {
+                    gz, err := gzip.NewReader(&bufs[i])
+                    if err != nil {
+                        t.Fatalf("shard %d: %v", i, err)
+                    }
+                    data, err = io.ReadAll(gz)
+                    if err != nil {
+                        t.Fatalf("shard %d: %v", i, err)
+                    }
+                }
+
+                if len(data) > 0 && data[len(data)-1] != '\n' {
+                    t.Errorf("shard %d does not end with a newline", i)
+                }
+                got = append(got, data...)
+            }
+
+            if !bytes.Equal(got, tt.want) {
+                t.Errorf("got %d bytes, want %d", len(got), len(tt.want))
+            }
+            if sum := sha256.Sum256(tt.want); !bytes.Equal(stats.SHA256, sum[:]) {
+                t.Error("wrong SHA-256 of the measurements")
+            }
+            if stats.Bytes != int64(len(tt.want)) {
+                t.Errorf("counted %d bytes, want %d", stats.Bytes, len(tt.want))
+            }
+
+            // the aggregates only cover the lines written, with the
+            // same result computed by reading them back
+            var result bytes.Buffer
+            err = g.Result(&result)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if diff := diffResults(result.String(), reference(t, string(tt.want))); diff != "" {
+                t.Error(diff)
+            }
+        })
+    }
+}
+
+func TestGeneratorErrors(t *testing.T) {
+    for _, tt := range []struct {
+        name  string
+        apply func(g *Generator)
+    }{
+        { "no stations", func(g *Generator) { g.Stations = []Station{} } },
+        { "outliers", func(g *Generator) { g.Values.Outliers = 1.5 } },
+        { "corruption", func(g *Generator) { g.Values.Corrupt.Fraction = -0.1 } },
+        { "defect kinds", func(g *Generator) { g.Values.Corrupt = Corruption{ Fraction: 0.1 } } },
+    } {
+        g := New(42, 10)
+        tt.apply(g)
+        if _, err := g.WriteTo(io.Discard); err == nil {
+            t.Errorf("%s: expected an error", tt.name)
+        }
+        if err := g.Result(io.Discard); err == nil {
+            t.Errorf("%s: expected no result", tt.name)
+        }
+    }
+}
+
+func TestParseTarget(t *testing.T) {
+    for s, want := range map[string]Target{
+        "1000000000": { Rows: 1000000000 },
+        "0":          { Rows: 0 },
+        "100B":       { Bytes: 100 },
+        "5GB":        { Bytes: 5e9 },
+        "1.5KB":      { Bytes: 1500 },
+        "512MiB":     { Bytes: 512 << 20 },
+    } {
+        got, err := ParseTarget(s)
+        if err != nil {
+            t.Errorf("%s: %v", s, err)
+        } else if got != want {
+            t.Errorf("%s: got %v, want %v", s, got, want)
+        }
+    }
+
+    for _, s := range []string{ "", "-1", "5G", "GB", "1e9" } {
+        _, err := ParseTarget(s)
+        if err == nil {
+            t.Errorf("%s: expected an error", s)
+        }
+    }
+}
+
+// TestParseTargetShared checks ParseTarget with the sizes that calc reads
+// too, so that both take the same units
+func TestParseTargetShared(t *testing.T) {
+    data, err := os.ReadFile("../../testdata/sizes.tsv")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for _, line := range strings.Split(string(data), "\n") {
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        in, want, _ := strings.Cut(line, "\t")
+
+        got, err := ParseTarget(in)
+        if want == "invalid" {
+            if err == nil {
+                t.Errorf("%q: got %v, expected an error", in, got)
+            }
+        } else if err != nil || got.Rows != 0 || strconv.FormatInt(got.Bytes, 10) != want {
+            t.Errorf("%q: got %v, %v, want %s bytes", in, got, err, want)
+        }
+    }
+}
+
+func BenchmarkGenerate(b *testing.B) {
+    g := New(42, 10 * BUFFERED_LINES)
+
+    for range b.N {
+        n, err := g.WriteTo(io.Discard)
+        if err != nil {
+            b.Fatal(err)
+        }
+        b.SetBytes(n)
+    }
+}
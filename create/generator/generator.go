@@ -0,0 +1,127 @@
+// Package generator writes the measurements of the One Billion Row
+// Challenge, "<station>;<temperature>" lines drawn from configurable
+// distributions, along with their exact expected result. It is what the
+// create command runs, and it can feed measurements to any io.Writer, to
+// test or benchmark an implementation without going through a file.
+package generator
+
+import (
+    "errors"
+    "io"
+    "runtime"
+)
+
+// Generator writes measurements until its Target is reached. They only
+// depend on Seed, Target, Stations and Values, never on the number of
+// workers, so the same settings always give the same bytes
+type Generator struct {
+    Seed     uint64
+    Target   Target
+    Stations []Station // BuiltinStations if nil
+    Values   Values
+    Workers  int       // runtime.NumCPU() if not positive
+    Gzip     bool      // write every shard as a gzip stream
+
+    // Defects receives the corrupted lines written, if not nil, as a
+    // "offset\tline\tkind" line each
+    Defects io.Writer
+
+    aggregates []Aggregate
+    stats      Stats
+}
+
+// Stats describes the measurements written by a Generator
+type Stats struct {
+    Rows    int64
+    Bytes   int64  // before compression
+    Defects int64  // corrupted lines, which are left out of the result
+    SHA256  []byte // of the measurements before compression, as if all the shards were a single file
+
+    Shards []ShardStats
+}
+
+// ShardStats describes a shard as written, after compression
+type ShardStats struct {
+    Bytes  int64
+    SHA256 []byte
+}
+
+// New returns a Generator of the given number of lines with the built-in
+// stations and the distributions of the original challenge
+func New(seed uint64, rows int64) *Generator {
+    return &Generator{
+        Seed: seed,
+        Target: Target{ Rows: rows },
+        Values: DefaultValues(),
+    }
+}
+
+// WriteTo writes the measurements to w, implementing io.WriterTo. It
+// returns the number of bytes written to w, after compression
+func (g *Generator) WriteTo(w io.Writer) (int64, error) {
+    err := g.WriteShards([]io.Writer{ w })
+    return g.stats.Shards[0].Bytes, err
+}
+
+// WriteShards writes the measurements split in shards of roughly equal
+// size, in order: reading them one after the other gives the same lines
+// as WriteTo
+func (g *Generator) WriteShards(shards []io.Writer) error {
+    g.aggregates = nil
+    g.stats = Stats{ Shards: make([]ShardStats, len(shards)) }
+
+    stations := g.stations()
+    switch {
+    case len(shards) == 0:
+        return errors.New("no shards to write to")
+    case len(stations) == 0:
+        return errors.New("no stations")
+    case g.Values.Outliers < 0 || g.Values.Outliers > 1:
+        return errors.New("the fraction of outliers must be between 0 and 1")
+    case g.Values.Corrupt.Fraction < 0 || g.Values.Corrupt.Fraction > 1:
+        return errors.New("the fraction of corrupted lines must be between 0 and 1")
+    case g.Values.Corrupt.Fraction > 0 && len(g.Values.Corrupt.Kinds) == 0:
+        return errors.New("no kinds of defects to corrupt the lines with")
+    }
+
+    workers := g.Workers
+    if workers <= 0 {
+        workers = runtime.NumCPU()
+    }
+
+    out := newOutput(shards, g.Gzip, g.Target)
+    out.defects = g.Defects
+    aggregates, err := generate(out, stations, &g.Values, g.Seed, workers)
+    g.stats = out.stats()
+    if err != nil {
+        return err
+    }
+    g.aggregates = aggregates
+    return nil
+}
+
+// Result writes the expected result of the last measurements written
+func (g *Generator) Result(w io.Writer) error {
+    if g.aggregates == nil {
+        return errors.New("no measurements written")
+    }
+    return WriteResult(w, g.stations(), g.aggregates)
+}
+
+// Aggregates returns the statistics of the last measurements written,
+// indexed like Stations
+func (g *Generator) Aggregates() []Aggregate {
+    return g.aggregates
+}
+
+// Stats describes the last measurements written
+func (g *Generator) Stats() Stats {
+    return g.stats
+}
+
+func (g *Generator) stations() []Station {
+    if g.Stations == nil {
+        return BuiltinStations[:]
+    }
+    return g.Stations
+}
@@ -0,0 +1,276 @@
+package generator
+
+import (
+    "bytes"
+    "compress/gzip"
+    "crypto/sha256"
+    "fmt"
+    "hash"
+    "io"
+    "regexp"
+    "strconv"
+)
+
+// Target is the amount of data to generate: either a number of lines or
+// a size in bytes, in which case the last line that does not fit is left
+// out
+type Target struct {
+    Rows  int64
+    Bytes int64
+}
+
+var sizeRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([KMGT]i?)?B$`)
+
+var sizeUnits = map[string]float64{
+    "": 1,
+    "K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12,
+    "Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40,
+}
+
+// ParseTarget accepts a number of lines, like "1000000000", or a size in
+// bytes with its unit, like "5GB", "512MiB" or "100B", which calc reads
+// the same way: testdata/sizes.tsv holds the ones both must agree on
+func ParseTarget(s string) (Target, error) {
+    if m := sizeRegexp.FindStringSubmatch(s); m != nil {
+        n, err := strconv.ParseFloat(m[1], 64)
+        if err != nil {
+            return Target{}, err
+        }
+        return Target{ Bytes: int64(n * sizeUnits[m[2]]) }, nil
+    }
+
+    rows, err := strconv.ParseInt(s, 10, 64)
+    if err != nil || rows < 0 {
+        return Target{}, fmt.Errorf("invalid number of records or size %q", s)
+    }
+    return Target{ Rows: rows }, nil
+}
+
+func (t Target) String() string {
+    if t.Bytes > 0 {
+        return fmt.Sprintf("%d bytes", t.Bytes)
+    }
+    return fmt.Sprintf("%d measurements", t.Rows)
+}
+
+// output writes the generated chunks, which must be given in order, to
+// one or more shards of roughly equal size, optionally as gzip streams.
+// The chunks are compressed by the workers, one gzip member each, and
+// only those split between shards or truncated are compressed again here
+type output struct {
+    shards []io.Writer
+    gzip   bool
+    target Target
+
+    shard   int     // shard being written
+    sizes   []int64 // bytes written to every shard, after compression
+    rows    int64   // lines written so far
+    bytes   int64   // bytes written so far, before compression
+    stopped bool    // the target size has been reached
+
+    sum       hash.Hash   // SHA-256 of the measurements, before compression
+    shardSums []hash.Hash // SHA-256 of every shard, as written
+
+    // defects receives the corrupted lines written, if not nil, as their
+    // offset and line number in the measurements and their kind
+    defects  io.Writer
+    nDefects int64
+
+    gz *gzip.Writer
+}
+
+func newOutput(shards []io.Writer, compress bool, t Target) *output {
+    o := &output{
+        shards: shards,
+        gzip: compress,
+        target: t,
+        sizes: make([]int64, len(shards)),
+        sum: sha256.New(),
+        shardSums: make([]hash.Hash, len(shards)),
+    }
+    for i := range o.shardSums {
+        o.shardSums[i] = sha256.New()
+    }
+    return o
+}
+
+// limit returns the number of lines or of bytes that must be written,
+// counting the previous shards too, before moving to the next shard
+func (o *output) limit() int64 {
+    n := int64(len(o.shards))
+    if o.target.Bytes > 0 {
+        return o.target.Bytes * int64(o.shard+1) / n
+    }
+    return o.target.Rows * int64(o.shard+1) / n
+}
+
+func (o *output) written() int64 {
+    if o.target.Bytes > 0 {
+        return o.bytes
+    }
+    return o.rows
+}
+
+// nextShard moves to the next shard while the current one is full
+func (o *output) nextShard() {
+    for o.shard < len(o.shards)-1 && o.written() >= o.limit() {
+        o.shard++
+    }
+}
+
+// write writes the lines of c, setting c.written, and reports whether the
+// target size has been reached
+func (o *output) write(c *chunk) (bool, error) {
+    offset, line := o.bytes, o.rows
+    reached, err := o.writeLines(c)
+    if err != nil {
+        return reached, err
+    }
+    return reached, o.writeDefects(c, offset, line)
+}
+
+func (o *output) writeLines(c *chunk) (bool, error) {
+    if o.stopped {
+        return true, nil
+    }
+    o.nextShard()
+
+    amount := int64(c.lines)
+    if o.target.Bytes > 0 {
+        amount = int64(len(c.data))
+    }
+
+    if o.written() + amount <= o.limit() {
+        data := c.data
+        if o.gzip {
+            data = c.gz
+        }
+
+        err := o.writeShard(data)
+        o.sum.Write(c.data)
+        o.rows += int64(c.lines)
+        o.bytes += int64(len(c.data))
+        c.written = c.lines
+
+        o.stopped = o.target.Bytes > 0 && o.bytes == o.target.Bytes
+        return o.stopped, err
+    }
+
+    // the chunk must be split between shards or truncated, line by line
+    var start, i int
+    for i < len(c.data) {
+        end := i + bytes.IndexByte(c.data[i:], '\n') + 1
+        if o.target.Bytes > 0 && o.bytes + int64(end-i) > o.target.Bytes {
+            o.stopped = true
+            break
+        }
+
+        if o.shard < len(o.shards)-1 && o.written() >= o.limit() {
+            err := o.writePiece(c.data[start:i])
+            if err != nil {
+                return o.stopped, err
+            }
+            o.nextShard()
+            start = i
+        }
+
+        o.rows++
+        o.bytes += int64(end-i)
+        c.written++
+        i = end
+    }
+
+    o.stopped = o.stopped || o.target.Bytes > 0 && o.bytes == o.target.Bytes
+    return o.stopped, o.writePiece(c.data[start:i])
+}
+
+// writeDefects records the defects of the lines of c that were written,
+// starting at the given offset and line of the measurements
+func (o *output) writeDefects(c *chunk, offset int64, line int64) error {
+    for _, d := range c.defects {
+        if d.line >= c.written {
+            break
+        }
+        o.nDefects++
+
+        if o.defects == nil {
+            continue
+        }
+        _, err := fmt.Fprintf(o.defects, "%d\t%d\t%s\n", offset + int64(d.offset), line + int64(d.line) + 1, d.kind)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// writePiece writes the given lines to the current shard, compressing
+// them if needed
+func (o *output) writePiece(data []byte) error {
+    o.sum.Write(data)
+    if len(data) == 0 || !o.gzip {
+        return o.writeShard(data)
+    }
+
+    var buf bytes.Buffer
+    if o.gz == nil {
+        o.gz, _ = gzip.NewWriterLevel(&buf, GZIP_LEVEL)
+    } else {
+        o.gz.Reset(&buf)
+    }
+
+    o.gz.Write(data)
+    o.gz.Close()
+    return o.writeShard(buf.Bytes())
+}
+
+func (o *output) writeShard(data []byte) error {
+    if len(data) == 0 {
+        return nil
+    }
+
+    n, err := o.shards[o.shard].Write(data)
+    o.shardSums[o.shard].Write(data[:n])
+    o.sizes[o.shard] += int64(n)
+    return err
+}
+
+// finish completes the shards that were never written: a gzip stream
+// needs at least an empty member to be valid
+func (o *output) finish() error {
+    if !o.gzip {
+        return nil
+    }
+
+    for i, size := range o.sizes {
+        if size > 0 {
+            continue
+        }
+
+        var buf bytes.Buffer
+        gzip.NewWriter(&buf).Close()
+
+        n, err := o.shards[i].Write(buf.Bytes())
+        o.shardSums[i].Write(buf.Bytes()[:n])
+        o.sizes[i] += int64(n)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// stats returns what was written so far
+func (o *output) stats() Stats {
+    s := Stats{
+        Rows: o.rows,
+        Bytes: o.bytes,
+        Defects: o.nDefects,
+        SHA256: o.sum.Sum(nil),
+        Shards: make([]ShardStats, len(o.shards)),
+    }
+    for i := range s.Shards {
+        s.Shards[i] = ShardStats{ Bytes: o.sizes[i], SHA256: o.shardSums[i].Sum(nil) }
+    }
+    return s
+}
@@ -0,0 +1,136 @@
+package generator
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// Aggregate holds the statistics of a station in integer tenths of a
+// degree, so that they are exactly those of the written values
+type Aggregate struct {
+    Min   int64
+    Max   int64
+    Acc   int64
+    Count int
+}
+
+// Add adds a value to the statistics
+func (a *Aggregate) Add(t int64) {
+    if a.Count == 0 {
+        *a = Aggregate{ Min: t, Max: t, Acc: t, Count: 1 }
+        return
+    }
+    a.Min = min(a.Min, t)
+    a.Max = max(a.Max, t)
+    a.Acc += t
+    a.Count++
+}
+
+// Merge adds the values of b to the statistics
+func (a *Aggregate) Merge(b Aggregate) {
+    if b.Count == 0 {
+        return
+    }
+    if a.Count == 0 {
+        *a = b
+        return
+    }
+    a.Min = min(a.Min, b.Min)
+    a.Max = max(a.Max, b.Max)
+    a.Acc += b.Acc
+    a.Count += b.Count
+}
+
+// WriteResult writes the result of the challenge for the given stations,
+// where aggregates[i] holds the values of stations[i]. The stations that
+// never appear in the measurements are left out
+func WriteResult(w io.Writer, stations []Station, aggregates []Aggregate) error {
+    indexes := make([]int, 0, len(stations))
+    for i := range stations {
+        if aggregates[i].Count > 0 {
+            indexes = append(indexes, i)
+        }
+    }
+    slices.SortFunc(indexes, func(a, b int) int {
+        return strings.Compare(stations[a].Name, stations[b].Name)
+    })
+
+    out := bufio.NewWriter(w)
+    fmt.Fprint(out, "{\n")
+    for i, index := range indexes {
+        if i > 0 {
+            fmt.Fprint(out, ",\n")
+        }
+        a := aggregates[index]
+        fmt.Fprintf(out, "\t%s=%s/%s/%s", stations[index].Name, FormatTenths(a.Min), FormatTenths(RoundMean(a.Acc, a.Count)), FormatTenths(a.Max))
+    }
+    fmt.Fprint(out, "\n}\n")
+
+    return out.Flush()
+}
+
+// ParseTenths parses a temperature with exactly one decimal digit, like
+// "-12.3", as an integer number of tenths. Values outside of the range of
+// the challenge are accepted, as the generator writes them when Clamp is false
+func ParseTenths(s string) (int64, error) {
+    digits := strings.TrimPrefix(s, "-")
+    negative := len(digits) < len(s)
+
+    intPart, fracPart, found := strings.Cut(digits, ".")
+    if !found || len(intPart) == 0 || len(intPart) > 15 || len(fracPart) != 1 {
+        return 0, fmt.Errorf("invalid temperature %q", s)
+    }
+
+    var t int64
+    for _, c := range []byte(intPart + fracPart) {
+        if c < '0' || c > '9' {
+            return 0, fmt.Errorf("invalid temperature %q", s)
+        }
+        t = t*10 + int64(c-'0')
+    }
+
+    if negative {
+        t = -t
+    }
+    return t, nil
+}
+
+// RoundMean returns acc / count rounded half up, which is the rule
+// used by the reference implementation (Java's Math.round)
+func RoundMean(acc int64, count int) int64 {
+    n, d := 2*acc+int64(count), 2*int64(count)
+    q := n / d
+    if n%d != 0 && n < 0 {
+        q--
+    }
+    return q
+}
+
+// FormatTenths prints a value expressed in tenths with exactly one
+// decimal digit, without ever producing "-0.0"
+func FormatTenths(t int64) string {
+    return string(AppendTenths(make([]byte, 0, 8), t))
+}
+
+// AppendTenths appends FormatTenths(t) to buf without going through fmt,
+// as the generator does it for every line
+func AppendTenths(buf []byte, t int64) []byte {
+    if t < 0 {
+        buf = append(buf, '-')
+        t = -t
+    }
+
+    switch {
+    case t < 100:
+        buf = append(buf, byte('0' + t/10))
+    case t < 1000:
+        buf = append(buf, byte('0' + t/100), byte('0' + t/10%10))
+    default:
+        buf = strconv.AppendInt(buf, t/10, 10)
+    }
+    return append(buf, '.', byte('0' + t%10))
+}
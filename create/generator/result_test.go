@@ -0,0 +1,114 @@
+package generator
+
+import (
+    "bytes"
+    "fmt"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+// reference computes the result of the measurements line by line, as the
+// oracle of the aggregates of the generator
+func reference(t *testing.T, data string) string {
+    index := make(map[string]int)
+    var stations []Station
+    var aggregates []Aggregate
+
+    for i, line := range strings.Split(strings.TrimSuffix(data, "\n"), "\n") {
+        if line == "" {
+            continue
+        }
+        name, temp, _ := strings.Cut(line, ";")
+        v, err := ParseTenths(temp)
+        if err != nil {
+            t.Fatalf("line %d: %v", i+1, err)
+        }
+
+        j, found := index[name]
+        if !found {
+            j = len(stations)
+            index[name] = j
+            stations = append(stations, Station{ Name: name })
+            aggregates = append(aggregates, Aggregate{})
+        }
+        aggregates[j].Add(v)
+    }
+
+    var out bytes.Buffer
+    if err := WriteResult(&out, stations, aggregates); err != nil {
+        t.Fatal(err)
+    }
+    return out.String()
+}
+
+// diffResults describes the first difference between two results, or
+// returns "" if they are the same
+func diffResults(got string, want string) string {
+    if got == want {
+        return ""
+    }
+
+    gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+    for i := range min(len(gotLines), len(wantLines)) {
+        if gotLines[i] != wantLines[i] {
+            return fmt.Sprintf("line %d: got %q, want %q", i+1, gotLines[i], wantLines[i])
+        }
+    }
+    return fmt.Sprintf("got %d lines, want %d", len(gotLines), len(wantLines))
+}
+
+func TestWriteResult(t *testing.T) {
+    stations := []Station{ { Name: "b" }, { Name: "B" }, { Name: "é" }, { Name: "a" }, { Name: "unused" } }
+    aggregates := make([]Aggregate, len(stations))
+    for _, v := range []int64{ 1, 2 } {
+        aggregates[0].Add(v)
+    }
+    for _, v := range []int64{ -1, -2 } {
+        aggregates[1].Add(v)
+    }
+    aggregates[2].Add(999)
+    aggregates[3].Merge(Aggregate{ Min: -5, Max: 5, Acc: 0, Count: 3 })
+    aggregates[3].Merge(Aggregate{})
+
+    var out bytes.Buffer
+    if err := WriteResult(&out, stations, aggregates); err != nil {
+        t.Fatal(err)
+    }
+
+    // the means are rounded half up and "-0.0" is never written
+    want := "{\n\tB=-0.2/-0.1/-0.1,\n\ta=-0.5/0.0/0.5,\n\tb=0.1/0.2/0.2,\n\té=99.9/99.9/99.9\n}\n"
+    if diff := diffResults(out.String(), want); diff != "" {
+        t.Error(diff)
+    }
+}
+
+func TestParseTenths(t *testing.T) {
+    for s, want := range map[string]int64{
+        "0.0": 0, "-0.0": 0, "9.9": 99, "-12.3": -123, "99.9": 999, "-99.9": -999, "1234.5": 12345,
+    } {
+        got, err := ParseTenths(s)
+        if err != nil {
+            t.Errorf("%s: %v", s, err)
+        } else if got != want {
+            t.Errorf("%s: got %d, want %d", s, got, want)
+        }
+    }
+
+    for _, s := range []string{ "", "-", ".", "1.", ".1", "1", "1.23", "-.5", "1,0", "a.b", "1.0 " } {
+        _, err := ParseTenths(s)
+        if err == nil {
+            t.Errorf("%q: expected an error", s)
+        }
+    }
+}
+
+func TestAppendTenths(t *testing.T) {
+    for v := int64(-20000); v <= 20000; v++ {
+        want := strconv.FormatFloat(float64(v) / 10, 'f', 1, 64)
+
+        if got := string(AppendTenths(nil, v)); got != want {
+            t.Fatalf("%d: got %q, want %q", v, got, want)
+        }
+    }
+}
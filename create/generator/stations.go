@@ -0,0 +1,258 @@
+package generator
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "math/rand/v2"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// MAX_NAME_LENGTH is the maximum length in bytes of a station name
+const MAX_NAME_LENGTH = 100
+
+// Station is a weather station, whose measurements are drawn around its
+// mean temperature
+type Station struct {
+    Name     string
+    MeanTemp float64
+}
+
+// Script is a set of characters used to synthesise station names, given
+// as inclusive ranges of code points
+type Script [][2]rune
+
+var scripts = map[string]Script{
+    "latin":    { {'a', 'z'}, {'A', 'Z'} },
+    "accented": { {'À', 'Ö'}, {'Ø', 'ö'}, {'ø', 'ſ'} },
+    "greek":    { {'Α', 'Ρ'}, {'Σ', 'Ω'}, {'α', 'ω'} },
+    "cyrillic": { {'Ѐ', 'ҁ'}, {'Ҋ', 'ӿ'} },
+    "cjk":      { {'一', '鿿'} },
+}
+
+func (s Script) size() int {
+    var n int
+    for _, r := range s {
+        n += int(r[1]-r[0]) + 1
+    }
+    return n
+}
+
+func (s Script) rune(i int) rune {
+    for _, r := range s {
+        if n := int(r[1]-r[0]) + 1; i >= n {
+            i -= n
+        } else {
+            return r[0] + rune(i)
+        }
+    }
+    panic("rune index out of range")
+}
+
+func (s Script) randomRune(r *rand.Rand) rune {
+    return s.rune(r.IntN(s.size()))
+}
+
+// ParseScripts parses a comma separated list of script names
+func ParseScripts(list string) ([]Script, error) {
+    var result []Script
+    for _, name := range strings.Split(list, ",") {
+        s, ok := scripts[strings.TrimSpace(name)]
+        if !ok {
+            return nil, fmt.Errorf("unknown script %q", name)
+        }
+        result = append(result, s)
+    }
+    return result, nil
+}
+
+// NameLength is the distribution of the length in bytes of the
+// synthesised station names. It implements flag.Value and accepts
+// "fixed:N", "uniform:MIN-MAX" and "normal:MEAN,SIGMA"; every length is
+// clamped between 1 and MAX_NAME_LENGTH
+type NameLength struct {
+    kind string
+    a, b float64
+}
+
+// DefaultNameLength is between 3 and 24 bytes, like most of the built-in
+// names
+func DefaultNameLength() NameLength {
+    return NameLength{ kind: "uniform", a: 3, b: 24 }
+}
+
+func (d *NameLength) String() string {
+    switch d.kind {
+    case "fixed":
+        return fmt.Sprintf("fixed:%g", d.a)
+    case "uniform":
+        return fmt.Sprintf("uniform:%g-%g", d.a, d.b)
+    case "normal":
+        return fmt.Sprintf("normal:%g,%g", d.a, d.b)
+    default:
+        return ""
+    }
+}
+
+func (d *NameLength) Set(value string) error {
+    kind, params, _ := strings.Cut(value, ":")
+    var err error
+
+    switch kind {
+    case "fixed":
+        d.a, err = strconv.ParseFloat(params, 64)
+    case "uniform":
+        d.a, d.b, err = parsePair(params, "-")
+        if err == nil && d.a > d.b {
+            err = fmt.Errorf("min %g is greater than max %g", d.a, d.b)
+        }
+    case "normal":
+        d.a, d.b, err = parsePair(params, ",")
+    default:
+        err = fmt.Errorf("unknown name length distribution %q", kind)
+    }
+
+    if err != nil {
+        return err
+    }
+    d.kind = kind
+    return nil
+}
+
+func (d *NameLength) sample(r *rand.Rand) int {
+    var l float64
+    switch d.kind {
+    case "fixed":
+        l = d.a
+    case "uniform":
+        l = d.a + r.Float64() * (d.b - d.a + 1)
+    case "normal":
+        l = r.NormFloat64() * d.b + d.a
+    }
+    return min(max(int(math.Floor(l)), 1), MAX_NAME_LENGTH)
+}
+
+func parsePair(s string, sep string) (float64, float64, error) {
+    first, second, ok := strings.Cut(s, sep)
+    if !ok {
+        return 0, 0, fmt.Errorf("expected two values separated by %q, got %q", sep, s)
+    }
+
+    a, err := strconv.ParseFloat(first, 64)
+    if err != nil {
+        return 0, 0, err
+    }
+    b, err := strconv.ParseFloat(second, 64)
+    if err != nil {
+        return 0, 0, err
+    }
+    return a, b, nil
+}
+
+// LoadStations reads a catalogue of stations in the format of the
+// weather_stations.csv of the original challenge: a "name;mean" line per
+// station, where the lines starting with '#' and the empty ones are
+// ignored. Only the first station with a name is kept, as the result
+// could not tell them apart, and the others are counted as duplicates
+func LoadStations(in io.Reader) ([]Station, int, error) {
+    var stations []Station
+    var duplicates int
+    names := make(map[string]struct{})
+
+    sc := bufio.NewScanner(in)
+    line := 0
+    for sc.Scan() {
+        line++
+
+        text := sc.Text()
+        if line == 1 {
+            text = strings.TrimPrefix(text, "\uFEFF")
+        }
+        if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+            continue
+        }
+
+        name, meanString, found := strings.Cut(text, ";")
+        if !found {
+            return nil, 0, fmt.Errorf("line %d: missing ';' in %q", line, text)
+        }
+        if name == "" || len(name) > MAX_NAME_LENGTH || !utf8.ValidString(name) {
+            return nil, 0, fmt.Errorf("line %d: invalid station name %q", line, name)
+        }
+        mean, err := strconv.ParseFloat(strings.TrimSpace(meanString), 64)
+        if err != nil {
+            return nil, 0, fmt.Errorf("line %d: %w", line, err)
+        }
+
+        if _, found := names[name]; found {
+            duplicates++
+            continue
+        }
+        names[name] = struct{}{}
+        stations = append(stations, Station{ Name: name, MeanTemp: mean })
+    }
+    if err := sc.Err(); err != nil {
+        return nil, 0, fmt.Errorf("line %d: %w", line+1, err)
+    }
+
+    if len(stations) == 0 {
+        return nil, 0, errors.New("no stations")
+    }
+    return stations, duplicates, nil
+}
+
+// SynthesiseStations creates n stations with unique names, each written
+// with one of the given scripts and long as many bytes as drawn from
+// nameLength. Their mean temperatures are between -20 and 30 degrees
+func SynthesiseStations(r *rand.Rand, n int, scripts []Script, nameLength *NameLength) ([]Station, error) {
+    stations := make([]Station, 0, n)
+    names := make(map[string]struct{}, n)
+
+    // every name is retried until it is unique, but some settings do not
+    // allow n different names, like a fixed length of 1 with 52 letters
+    var failures int
+    for len(stations) < n {
+        name := synthesiseName(r, scripts[r.IntN(len(scripts))], nameLength.sample(r))
+        if _, found := names[name]; found {
+            failures++
+            if failures == 1000 {
+                return nil, fmt.Errorf("cannot synthesise %d unique station names, stopped at %d", n, len(stations))
+            }
+            continue
+        }
+        failures = 0
+
+        names[name] = struct{}{}
+        stations = append(stations, Station{
+            Name: name,
+            MeanTemp: math.Round(r.Float64() * 500 - 200) / 10,
+        })
+    }
+
+    return stations, nil
+}
+
+// synthesiseName creates a name of exactly length bytes with characters
+// of s, with some spaces between them. If the characters of s do not fit
+// exactly, the name is padded with ASCII letters
+func synthesiseName(r *rand.Rand, s Script, length int) string {
+    latin := scripts["latin"]
+    name := make([]byte, 0, length)
+
+    for len(name) < length {
+        c := s.randomRune(r)
+        switch {
+        case len(name) + utf8.RuneLen(c) > length:
+            c = latin.randomRune(r)
+        case len(name) > 0 && len(name) < length-1 && name[len(name)-1] != ' ' && r.IntN(8) == 0:
+            c = ' '
+        }
+        name = utf8.AppendRune(name, c)
+    }
+
+    return string(name)
+}
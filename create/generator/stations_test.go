@@ -0,0 +1,48 @@
+package generator
+
+import (
+    "fmt"
+    "strings"
+    "testing"
+)
+
+func TestLoadStations(t *testing.T) {
+    // the built-in stations, written like weather_stations.csv
+    var sb strings.Builder
+    sb.WriteString("# Adapted from https://simplemaps.com/data/world-cities\n")
+    sb.WriteString("# Licensed under Creative Commons Attribution 4.0\n\n")
+    for _, ws := range BuiltinStations {
+        fmt.Fprintf(&sb, "%s;%g\r\n", ws.Name, ws.MeanTemp)
+    }
+    sb.WriteString("Abha;-5.0\n")
+
+    stations, duplicates, err := LoadStations(strings.NewReader(sb.String()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if duplicates != 1 {
+        t.Errorf("got %d duplicates, want 1", duplicates)
+    }
+    if len(stations) != len(BuiltinStations) {
+        t.Fatalf("got %d stations, want %d", len(stations), len(BuiltinStations))
+    }
+    for i, ws := range stations {
+        if ws != BuiltinStations[i] {
+            t.Errorf("got %v, want %v", ws, BuiltinStations[i])
+        }
+    }
+
+    for in, want := range map[string]string{
+        "":                            "no stations",
+        "# only comments\n":           "no stations",
+        "Abha;18.0\nTokyo 35.6897\n":  "line 2: missing ';'",
+        ";18.0\n":                     "line 1: invalid station name",
+        "Abha;warm\n":                 "line 1: strconv.ParseFloat",
+        strings.Repeat("x", 101) + ";1": "line 1: invalid station name",
+    } {
+        _, _, err := LoadStations(strings.NewReader(in))
+        if err == nil || !strings.Contains(err.Error(), want) {
+            t.Errorf("%q: got %v, want %q", in, err, want)
+        }
+    }
+}
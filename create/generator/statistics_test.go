@@ -0,0 +1,166 @@
+package generator
+
+import (
+    "bytes"
+    "fmt"
+    "math"
+    "regexp"
+    "strings"
+    "testing"
+)
+
+// STATISTICS_ROWS is the size of the samples of the statistical tests:
+// enough for the sample means to be within a fraction of a degree
+const STATISTICS_ROWS = 3 * BUFFERED_LINES + 12345
+
+// temperatureRegexp matches the values allowed by the challenge, with
+// exactly one decimal digit and never "-0.0"
+var temperatureRegexp = regexp.MustCompile(`^(-?[1-9]?[0-9]\.[0-9])$`)
+
+// sample generates STATISTICS_ROWS lines with a fixed seed, checking that
+// every one of them is well formed, and returns the aggregates
+func sample(t *testing.T, values *Values) []Aggregate {
+    var buf bytes.Buffer
+    g := New(42, STATISTICS_ROWS)
+    g.Values = *values
+    g.Workers = 4
+    _, err := g.WriteTo(&buf)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    names := make(map[string]bool, len(BuiltinStations))
+    for _, ws := range BuiltinStations {
+        names[ws.Name] = true
+    }
+
+    lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+    if len(lines) != STATISTICS_ROWS {
+        t.Fatalf("got %d lines, want %d", len(lines), STATISTICS_ROWS)
+    }
+    for i, line := range lines {
+        name, temp, _ := strings.Cut(line, ";")
+        if !names[name] || !temperatureRegexp.MatchString(temp) || temp == "-0.0" {
+            t.Fatalf("line %d: invalid %q", i+1, line)
+        }
+    }
+
+    return g.Aggregates()
+}
+
+func TestStationMeans(t *testing.T) {
+    for _, tt := range []struct {
+        spread string
+        sigma  float64
+    }{
+        { "normal:10", 10 },
+        { "uniform:15", 15 / math.Sqrt(3) },
+        { "bimodal:20,5", math.Sqrt(20*20 + 5*5) },
+    } {
+        t.Run(tt.spread, func(t *testing.T) {
+            values := DefaultValues()
+            if err := values.Spread.Set(tt.spread); err != nil {
+                t.Fatal(err)
+            }
+
+            for i, a := range sample(t, &values) {
+                // 5 standard deviations of the sample mean, plus the
+                // rounding of every value to a tenth
+                mean := float64(a.Acc) / float64(a.Count) / 10
+                bound := 5 * tt.sigma / math.Sqrt(float64(a.Count)) + 0.05
+                if math.Abs(mean - BuiltinStations[i].MeanTemp) > bound {
+                    t.Errorf("%s: sample mean %.3f of %d values, want %.1f ± %.3f", BuiltinStations[i].Name, mean, a.Count, BuiltinStations[i].MeanTemp, bound)
+                }
+            }
+        })
+    }
+}
+
+func TestSpreadInvalid(t *testing.T) {
+    for _, value := range []string{ "normal:-1", "uniform:-0.5", "bimodal:20,-5", "normal:x", "cauchy:1" } {
+        var d Spread
+        if err := d.Set(value); err == nil {
+            t.Errorf("%q: expected an error", value)
+        }
+    }
+}
+
+func TestPopularity(t *testing.T) {
+    n := len(BuiltinStations)
+    uniform := make([]float64, n)
+    for i := range uniform {
+        uniform[i] = 1 / float64(n)
+    }
+
+    for _, tt := range []struct {
+        popularity string
+        p          func(i int) float64 // unnormalised probability of station i
+    }{
+        { "uniform", func(int) float64 { return 1 } },
+        { "zipf:1.2", func(i int) float64 { return math.Pow(float64(1 + i), -1.2) } },
+        { "hot:0.3", func(i int) float64 {
+            if i == 0 {
+                return 0.3
+            }
+            return 0.7 / float64(n - 1)
+        } },
+    } {
+        t.Run(tt.popularity, func(t *testing.T) {
+            values := DefaultValues()
+            if err := values.Popularity.Set(tt.popularity); err != nil {
+                t.Fatal(err)
+            }
+            aggregates := sample(t, &values)
+
+            expected := make([]float64, n)
+            var total float64
+            for i := range expected {
+                expected[i] = tt.p(i)
+                total += expected[i]
+            }
+            for i := range expected {
+                expected[i] /= total
+            }
+
+            if ok, report := chiSquared(aggregates, expected); !ok {
+                t.Error(report)
+            }
+            // and the test can tell a distribution from another
+            if ok, _ := chiSquared(aggregates, uniform); ok && tt.popularity != "uniform" {
+                t.Error("the counts also fit a uniform distribution")
+            }
+        })
+    }
+}
+
+// chiSquared checks with Pearson's test whether the counts of the
+// aggregates fit the given probabilities, which sum to 1. The stations
+// expected less than 5 times are pooled, and the fit is rejected only if
+// the statistic is 6 standard deviations above its mean
+func chiSquared(aggregates []Aggregate, p []float64) (bool, string) {
+    var rows float64
+    for _, a := range aggregates {
+        rows += float64(a.Count)
+    }
+
+    var chi2, pooledExpected, pooledObserved float64
+    bins := 0
+    for i, a := range aggregates {
+        e := p[i] * rows
+        if e < 5 {
+            pooledExpected += e
+            pooledObserved += float64(a.Count)
+            continue
+        }
+        chi2 += (float64(a.Count) - e) * (float64(a.Count) - e) / e
+        bins++
+    }
+    if pooledExpected > 0 {
+        chi2 += (pooledObserved - pooledExpected) * (pooledObserved - pooledExpected) / pooledExpected
+        bins++
+    }
+
+    dof := float64(bins - 1)
+    limit := dof + 6 * math.Sqrt(2 * dof)
+    return chi2 <= limit, fmt.Sprintf("chi-squared %.1f with %.0f degrees of freedom, above %.1f", chi2, dof, limit)
+}
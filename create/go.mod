@@ -2,7 +2,4 @@ module create
 
 go 1.22.4
 
-require (
-	github.com/nixpare/broadcaster v1.2.1
-	golang.org/x/exp v0.0.0-20240716175740-e3f259677ff7
-)
+require golang.org/x/exp v0.0.0-20240716175740-e3f259677ff7
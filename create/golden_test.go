@@ -0,0 +1,231 @@
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "create/generator"
+)
+
+// goldenDir holds the corpus shared by every implementation: each case
+// is made of <name>.txt and the expected <name>-result.txt
+const goldenDir = "../testdata/golden"
+
+var update = flag.Bool("update", false, "regenerate the golden corpus in " + goldenDir)
+
+type goldenCase struct {
+    name  string
+    build func() []byte
+}
+
+var goldenCases = []goldenCase{
+    { name: "empty", build: func() []byte { return nil } },
+    { name: "single-line", build: func() []byte { return []byte("Hamburg;12.0\n") } },
+    { name: "no-trailing-newline", build: func() []byte {
+        return []byte("Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;34.2\nBulawayo;-1.5")
+    } },
+    { name: "all-negative", build: func() []byte {
+        return lines(
+            "Yakutsk;-40.2", "Anadyr;-0.1", "Yakutsk;-12.7", "Anadyr;-0.2",
+            "Dikson;-0.0", "Dikson;-0.1", "Yellowknife;-5.5", "Yakutsk;-99.9",
+            "Yellowknife;-5.6", "Anadyr;-0.3", "Nuuk;-0.4", "Nuuk;-0.1",
+        )
+    } },
+    { name: "extremes", build: func() []byte {
+        return lines(
+            "Hot;99.9", "Cold;-99.9", "Both;99.9", "Both;-99.9", "Hot;99.9",
+            "Cold;-99.9", "Both;99.9", "Both;-99.8", "Zero;0.0", "Zero;-0.0",
+            "Tie;0.1", "Tie;0.2", "NegTie;-0.1", "NegTie;-0.2",
+        )
+    } },
+    { name: "crlf", build: func() []byte {
+        return bytes.ReplaceAll(lines("Hamburg;12.0", "Bulawayo;8.9", "Hamburg;-3.4", "Abéché;-0.1"), []byte("\n"), []byte("\r\n"))
+    } },
+    { name: "bom", build: func() []byte {
+        return append([]byte("\uFEFF"), lines("Hamburg;12.0", "Bulawayo;8.9", "Hamburg;-3.4")...)
+    } },
+    { name: "crlf-bom-no-trailing-newline", build: func() []byte {
+        return []byte("\uFEFFHamburg;12.0\r\nBulawayo;8.9\r\nHamburg;-3.4\r\nBulawayo;-99.9")
+    } },
+    { name: "crlf-boundary", build: func() []byte {
+        // lines of 16 bytes once converted to CRLF, after a first one of
+        // 17: every boundary that is a multiple of 16 falls between the
+        // '\r' and the '\n' of a line
+        b := appendLine(nil, 16, "Station-9", "-12.3")
+        for i := range 63 {
+            b = appendLine(b, 15, fmt.Sprintf("Station-%d", i%10), temp4(i*7))
+        }
+        return bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n"))
+    } },
+    { name: "unique-10000", build: uniqueStations },
+    { name: "utf8-100-bytes", build: longNames },
+    { name: "chunk-boundary", build: func() []byte {
+        // 64 lines of 16 bytes: every chunk and buffer boundary that is a
+        // multiple of 16 falls right after a '\n'
+        var b []byte
+        for i := range 64 {
+            b = appendLine(b, 16, fmt.Sprintf("Station-%02d", i%16), temp4(i))
+        }
+        return b
+    } },
+    { name: "chunk-boundary-newline", build: func() []byte {
+        // a first line of 17 bytes shifts the 16 bytes lines so that
+        // every boundary that is a multiple of 16 falls on a '\n'
+        b := appendLine(nil, 17, "Station-99", "-12.3")
+        for i := range 63 {
+            b = appendLine(b, 16, fmt.Sprintf("Station-%02d", i%16), temp4(i*3))
+        }
+        return b
+    } },
+    { name: "utf8-boundary", build: func() []byte {
+        // 1024 bytes where every multiple of 16 splits the first 'é'
+        // of a line in two
+        b := appendLine(nil, 13, "Starts", "-12.3")
+        for i := range 62 {
+            b = appendLine(b, 16, fmt.Sprintf("%02déééé", i%8), temp4(i*5))
+        }
+        return appendLine(b, 19, "Last-Station", "-12.3")
+    } },
+}
+
+func TestGoldenCorpus(t *testing.T) {
+    for _, c := range goldenCases {
+        t.Run(c.name, func(t *testing.T) {
+            inPath := filepath.Join(goldenDir, c.name + ".txt")
+            resultPath := filepath.Join(goldenDir, c.name + "-result.txt")
+
+            data := c.build()
+            if *update {
+                err := os.WriteFile(inPath, data, 0644)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                dummy([]string{inPath}, resultPath)
+                return
+            }
+
+            checked, err := os.ReadFile(inPath)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !bytes.Equal(checked, data) {
+                t.Fatalf("%s is out of date, regenerate the corpus with -update", inPath)
+            }
+
+            want, err := os.ReadFile(resultPath)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            gotPath := filepath.Join(t.TempDir(), "result.txt")
+            dummy([]string{inPath}, gotPath)
+            got, err := os.ReadFile(gotPath)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            if diff := diffResults(string(got), string(want)); diff != "" {
+                t.Error(diff)
+            }
+        })
+    }
+}
+
+// diffResults returns a description of the first line where got differs
+// from want, or an empty string if they are the same
+func diffResults(got string, want string) string {
+    if got == want {
+        return ""
+    }
+
+    gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+    for i := range min(len(gotLines), len(wantLines)) {
+        if gotLines[i] != wantLines[i] {
+            return fmt.Sprintf("line %d: got %q, want %q", i+1, gotLines[i], wantLines[i])
+        }
+    }
+    return fmt.Sprintf("got %d lines, want %d", len(gotLines), len(wantLines))
+}
+
+func lines(l ...string) []byte {
+    return []byte(strings.Join(l, "\n") + "\n")
+}
+
+func tenths(t int) string {
+    return generator.FormatTenths(int64(t))
+}
+
+// temp4 returns a temperature that is always 4 bytes long, between
+// -9.9 and -1.0 or between 10.0 and 99.9
+func temp4(i int) string {
+    if i % 2 == 1 {
+        return tenths(-(i*37%90 + 10))
+    }
+    return tenths(i*37%900 + 100)
+}
+
+// appendLine appends "name;temp\n", failing if the line is not exactly
+// size bytes long, as the boundary cases rely on it
+func appendLine(b []byte, size int, name string, temp string) []byte {
+    line := name + ";" + temp + "\n"
+    if len(line) != size {
+        panic(fmt.Sprintf("line %q is %d bytes long, not %d", line, len(line), size))
+    }
+    return append(b, line...)
+}
+
+// uniqueStations creates 10.000 distinct stations, the maximum allowed
+// by the challenge, with one out of three appearing twice
+func uniqueStations() []byte {
+    const n = 10_000
+
+    var b []byte
+    for i := range n {
+        // 7 is coprime with n, so every station is visited exactly once
+        // but in a different order than their names
+        j := i * 7 % n
+        ws := generator.BuiltinStations[j % len(generator.BuiltinStations)]
+        name := fmt.Sprintf("%s %d", ws.Name, j / len(generator.BuiltinStations))
+
+        b = fmt.Appendf(b, "%s;%s\n", name, tenths(j*7919%1999-999))
+        if j % 3 == 0 {
+            b = fmt.Appendf(b, "%s;%s\n", name, tenths(j*104729%1999-999))
+        }
+    }
+    return b
+}
+
+// longNames creates stations with names of exactly 100 bytes, the
+// maximum allowed by the challenge, made of 1 to 4 bytes characters
+func longNames() []byte {
+    names := []string{
+        fill("", "é"), fill("", "東京"), fill("", "😀"), fill("", "Жюль"),
+        fill("x", "é"), fill("xy", "東"), fill("xyz", "😀"),
+        fill("São Paulo ", "ő"), fill("", "a"),
+        // same 99 bytes prefix, different last byte
+        fill("", "a")[:99] + "b",
+    }
+
+    var b []byte
+    for i := range 5 {
+        for j, name := range names {
+            b = fmt.Appendf(b, "%s;%s\n", name, tenths((i*31+j*17)%1999-999))
+        }
+    }
+    return b
+}
+
+// fill returns prefix followed by as many repetitions of s as possible,
+// padded with ASCII characters up to 100 bytes
+func fill(prefix string, s string) string {
+    name := prefix
+    for len(name) + len(s) <= 100 {
+        name += s
+    }
+    return name + strings.Repeat("_", 100 - len(name))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "create/generator"
+)
+
+// manifest describes how a dataset was generated, so that tools can check
+// that they run on the intended data and that a result belongs to it. It
+// is written next to the measurements as <name>.manifest.json
+type manifest struct {
+    Seed     uint64            `json:"seed"`
+    Target   string            `json:"target"`
+    Rows     int64             `json:"rows"`
+    Bytes    int64             `json:"bytes"`
+    Stations int               `json:"stations"`
+    Options  map[string]string `json:"options"`
+
+    // SHA256 is the hash of the measurements, before compression and as
+    // if all the shards were a single file
+    SHA256 string         `json:"sha256"`
+    Files  []manifestFile `json:"files"`
+
+    Result       string `json:"result"`
+    ResultSHA256 string `json:"result_sha256"`
+
+    // Defects is the number of corrupted lines, which are left out of the
+    // result and listed in DefectsFile
+    Defects     int64  `json:"defects,omitempty"`
+    DefectsFile string `json:"defects_file,omitempty"`
+}
+
+// manifestFile is a file of the dataset, as written on disk
+type manifestFile struct {
+    Name   string `json:"name"`
+    Bytes  int64  `json:"bytes"`
+    SHA256 string `json:"sha256"`
+}
+
+// newManifest describes the data written with the given stats, split in
+// the files at paths, whose result has the given SHA-256
+func newManifest(seed uint64, target string, stats generator.Stats, paths []string, aggregates []generator.Aggregate, options map[string]string, resPath string, resultSum []byte) *manifest {
+    m := &manifest{
+        Seed: seed,
+        Target: target,
+        Rows: stats.Rows,
+        Bytes: stats.Bytes,
+        Options: options,
+        SHA256: hex.EncodeToString(stats.SHA256),
+        Result: filepath.Base(resPath),
+        ResultSHA256: hex.EncodeToString(resultSum),
+    }
+
+    for _, a := range aggregates {
+        if a.Count > 0 {
+            m.Stations++
+        }
+    }
+
+    for i, path := range paths {
+        m.Files = append(m.Files, manifestFile{
+            Name: filepath.Base(path),
+            Bytes: stats.Shards[i].Bytes,
+            SHA256: hex.EncodeToString(stats.Shards[i].SHA256),
+        })
+    }
+
+    return m
+}
+
+func (m *manifest) write(path string) error {
+    data, err := json.MarshalIndent(m, "", "  ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// defectsPath returns the path of the list of the corrupted lines of the
+// measurements written to path, like "measurements-1.defects.tsv"
+func defectsPath(path string) string {
+    path = strings.TrimSuffix(path, ".gz")
+    return strings.TrimSuffix(path, ".txt") + ".defects.tsv"
+}
+
+// manifestPath returns the path of the manifest of the measurements
+// written to path, like "measurements-1.manifest.json" for
+// "measurements-1.txt.gz"
+func manifestPath(path string) string {
+    path = strings.TrimSuffix(path, ".gz")
+    return strings.TrimSuffix(path, ".txt") + ".manifest.json"
+}
+
+// shardPaths returns the path of every shard, inserting "-shard-K" before
+// the extensions of path if there is more than one
+func shardPaths(path string, n int) []string {
+    if n == 1 {
+        return []string{ path }
+    }
+
+    dir, file := filepath.Split(path)
+    base, ext := file, ""
+    if i := strings.Index(file, "."); i > 0 {
+        base, ext = file[:i], file[i:]
+    }
+
+    paths := make([]string, n)
+    for i := range paths {
+        paths[i] = filepath.Join(dir, fmt.Sprintf("%s-shard-%d%s", base, i, ext))
+    }
+    return paths
+}
+
+// resultPath returns the path of the expected result of the measurements
+// written to path, like "measurements-1-result.txt" for
+// "measurements-1.txt.gz"
+func resultPath(path string) string {
+    path = strings.TrimSuffix(path, ".gz")
+    return strings.TrimSuffix(path, ".txt") + "-result.txt"
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "os"
+    "strings"
+)
+
+// UTF8_BOM is the byte order mark some Windows tools put at the start
+// of UTF-8 files
+var UTF8_BOM = []byte{0xEF, 0xBB, 0xBF}
+
+type InputFormat struct {
+    BOM             bool // the file starts with a UTF-8 byte order mark
+    CRLF            bool // lines end with "\r\n" instead of "\n"
+    TrailingNewline bool // the last line ends with a newline
+}
+
+// detectFormat looks at the first line and at the last byte of the file
+// and leaves its offset at the first measurement
+func detectFormat(in *os.File) (InputFormat, error) {
+    format := InputFormat{TrailingNewline: true}
+
+    info, err := in.Stat()
+    if err != nil {
+        return format, err
+    }
+    if info.Size() == 0 {
+        return format, nil
+    }
+
+    // enough for the BOM and the longest line allowed
+    var buf [128]byte
+    n, err := in.ReadAt(buf[:], 0)
+    if err != nil && !errors.Is(err, io.EOF) {
+        return format, err
+    }
+    head := buf[:n]
+
+    format.BOM = bytes.HasPrefix(head, UTF8_BOM)
+    if i := bytes.IndexByte(head, 10); i > 0 {
+        format.CRLF = head[i-1] == 13
+    }
+
+    _, err = in.ReadAt(buf[:1], info.Size()-1)
+    if err != nil {
+        return format, err
+    }
+    format.TrailingNewline = buf[0] == 10
+
+    if format.BOM {
+        _, err = in.Seek(int64(len(UTF8_BOM)), io.SeekStart)
+    }
+    return format, err
+}
+
+func (format InputFormat) String() string {
+    var sb strings.Builder
+    if format.CRLF {
+        sb.WriteString("CRLF")
+    } else {
+        sb.WriteString("LF")
+    }
+    if format.BOM {
+        sb.WriteString(", BOM")
+    }
+    if !format.TrailingNewline {
+        sb.WriteString(", no trailing newline")
+    }
+    return sb.String()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// goldenDir holds the corpus shared by every implementation, generated
+// by the create module: each case is made of <name>.txt and the expected
+// <name>-result.txt
+const goldenDir = "../testdata/golden"
+
+func TestGolden(t *testing.T) {
+    inputs, err := filepath.Glob(filepath.Join(goldenDir, "*.txt"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for _, inPath := range inputs {
+        if strings.HasSuffix(inPath, "-result.txt") {
+            continue
+        }
+        name := strings.TrimSuffix(filepath.Base(inPath), ".txt")
+
+        want, err := os.ReadFile(filepath.Join(goldenDir, name+"-result.txt"))
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        // the buffer must be able to hold at least one full line, which
+        // is at most 107 bytes long
+        for _, workers := range []int{1, 4, N_WORKERS} {
+            for _, bufferSize := range []int{128, 4096, READ_BUFFER_SIZE} {
+                t.Run(fmt.Sprintf("%s/workers=%d/buffer=%d", name, workers, bufferSize), func(t *testing.T) {
+                    in, err := os.Open(inPath)
+                    if err != nil {
+                        t.Fatal(err)
+                    }
+                    defer in.Close()
+
+                    var out bytes.Buffer
+                    process(in, &out, workers, bufferSize)
+
+                    if diff := diffResults(out.String(), string(want)); diff != "" {
+                        t.Error(diff)
+                    }
+                })
+            }
+        }
+    }
+}
+
+// diffResults returns a description of the first line where got differs
+// from want, or an empty string if they are the same
+func diffResults(got string, want string) string {
+    if got == want {
+        return ""
+    }
+
+    gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+    for i := range min(len(gotLines), len(wantLines)) {
+        if gotLines[i] != wantLines[i] {
+            return fmt.Sprintf("line %d: got %q, want %q", i+1, gotLines[i], wantLines[i])
+        }
+    }
+    return fmt.Sprintf("got %d lines, want %d", len(gotLines), len(wantLines))
+}
@@ -1,10 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"os"
 	"slices"
 	"sort"
@@ -34,6 +34,9 @@ type StationData struct {
 var lock = &sync.Mutex{}
 var lockIdx = 0
 
+// crlf is set when the lines of the file end with "\r\n"
+var crlf = false
+
 func trashBin(input chan *TrashItem, output chan *swiss.Map[uint64, *StationData], wg *sync.WaitGroup) {
     defer wg.Done()
     data := swiss.NewMap[uint64, *StationData](1024)
@@ -46,6 +49,15 @@ func trashBin(input chan *TrashItem, output chan *swiss.Map[uint64, *StationData
         can = saveCan(can, data, buffer)
     }
 
+    // the tail of the last buffer has no head to be joined with: it is
+    // the last line of a file without a trailing newline
+    for _, item := range can {
+        if len(item.Value) > 0 {
+            _, nameInit, nameEnd, tempInit, tempEnd := nextLine(0, item.Value)
+            processLine(item.Value[nameInit:nameEnd], item.Value[tempInit:tempEnd], data)
+        }
+    }
+
     output <- data
 }
 
@@ -92,11 +104,11 @@ func saveCan(can []*TrashItem, data *swiss.Map[uint64, *StationData], buffer []b
     return can
 }
 
-func consumer(file *os.File, trash chan *TrashItem, output chan *swiss.Map[uint64, *StationData], wg *sync.WaitGroup) {
+func consumer(file *os.File, bufferSize int, trash chan *TrashItem, output chan *swiss.Map[uint64, *StationData], wg *sync.WaitGroup) {
     defer wg.Done()
     data := swiss.NewMap[uint64, *StationData](1024)
 
-    readBuffer := make([]byte, READ_BUFFER_SIZE)
+    readBuffer := make([]byte, bufferSize)
     for {
         lock.Lock()
         lockIdx++
@@ -111,25 +123,28 @@ func consumer(file *os.File, trash chan *TrashItem, output chan *swiss.Map[uint6
             panic(err)
         }
 
-        // ignoring first line
-        start := 0
+        // ignoring first line, which is the whole buffer if there
+        // is no newline (only the last read of the file)
+        start := n
         for i := 0; i < n; i++ {
             if readBuffer[i] == 10 {
                 start = i + 1
                 break
             }
         }
-        trash <- &TrashItem{idx - 1, readBuffer[:start], false}
+        // the trash keeps the values until their pair arrives, so they
+        // must not point into readBuffer, which is reused by the next read
+        trash <- &TrashItem{idx - 1, bytes.Clone(readBuffer[:start]), false}
 
-        // ignoring last line
-        final := 0
+        // ignoring last line, which is empty if there is no newline
+        final := n - 1
         for i := n - 1; i >= 0; i-- {
             if readBuffer[i] == 10 {
                 final = i
                 break
             }
         }
-        trash <- &TrashItem{idx, readBuffer[final+1 : n], true}
+        trash <- &TrashItem{idx, bytes.Clone(readBuffer[final+1 : n]), true}
 
         readingIndex := start
         for readingIndex < final {
@@ -157,6 +172,9 @@ func nextLine(readingIndex int, reading []byte) (nexReadingIndex, nameInit, name
         i++
     }
     tempEnd = i
+    if crlf && tempEnd > tempInit && reading[tempEnd-1] == 13 { // \r
+        tempEnd--
+    }
 
     readingIndex = i + 1
     return readingIndex, nameInit, nameEnd, tempInit, tempEnd
@@ -197,21 +215,33 @@ func run() {
 	}
 	defer in.Close()
 
-    outputChannels := make([]chan *swiss.Map[uint64, *StationData], N_WORKERS+1)
+    format := process(in, out, N_WORKERS, READ_BUFFER_SIZE)
+    fmt.Println("Input format:", format)
+}
+
+func process(in *os.File, out io.Writer, workers int, bufferSize int) InputFormat {
+    format, err := detectFormat(in)
+    if err != nil {
+        log.Fatalln(err)
+    }
+    crlf = format.CRLF
+
+    lockIdx = 0
+    outputChannels := make([]chan *swiss.Map[uint64, *StationData], workers+1)
 
     var wg sync.WaitGroup
     var wgTrash sync.WaitGroup
 
-    wg.Add(N_WORKERS)
+    wg.Add(workers)
     wgTrash.Add(1)
-    trash := make(chan *TrashItem, N_WORKERS*2)
+    trash := make(chan *TrashItem, workers*2)
     output := make(chan *swiss.Map[uint64, *StationData], 1)
     go trashBin(trash, output, &wgTrash)
     outputChannels[0] = output
 
-    for i := 0; i < N_WORKERS; i++ {
+    for i := 0; i < workers; i++ {
         output := make(chan *swiss.Map[uint64, *StationData], 1)
-        go consumer(in, trash, output, &wg)
+        go consumer(in, bufferSize, trash, output, &wg)
         outputChannels[i+1] = output
     }
 
@@ -219,12 +249,12 @@ func run() {
     close(trash)
     wgTrash.Wait()
 
-    for i := 0; i < N_WORKERS+1; i++ {
+    for i := 0; i < workers+1; i++ {
         close(outputChannels[i])
     }
 
     data := swiss.NewMap[uint64, *StationData](1000)
-    for i := 0; i < N_WORKERS+1; i++ {
+    for i := 0; i < workers+1; i++ {
         m := <-outputChannels[i]
         m.Iter(func(station uint64, stationData *StationData) bool {
             v, ok := data.Get(station)
@@ -246,6 +276,7 @@ func run() {
     }
 
     printResult(out, data)
+    return format
 }
 
 func hash(name []byte) uint64 {
@@ -271,16 +302,37 @@ func printResult(out io.Writer, data *swiss.Map[uint64, *StationData]) {
     fmt.Fprint(out, "{\n")
     for _, k := range keys {
         v := result[k]
+        mean := roundMean(v.Sum, v.Count)
         if first {
             first = false
-            fmt.Fprintf(out, "\t%s=%.1f/%.1f/%.1f", k, float64(v.Min)/10, math.Round(float64(v.Sum) / float64(v.Count)) / 10, float64(v.Max)/10)
+            fmt.Fprintf(out, "\t%s=%s/%s/%s", k, formatTenths(v.Min), formatTenths(mean), formatTenths(v.Max))
         } else {
-            fmt.Fprintf(out, ",\n\t%s=%.1f/%.1f/%.1f", k, float64(v.Min)/10, math.Round(float64(v.Sum) / float64(v.Count)) / 10, float64(v.Max)/10)
+            fmt.Fprintf(out, ",\n\t%s=%s/%s/%s", k, formatTenths(v.Min), formatTenths(mean), formatTenths(v.Max))
         }
     }
     fmt.Fprint(out, "\n}\n")
 }
 
+// roundMean returns sum / count rounded half up, like Java's Math.round
+// used by the reference implementation
+func roundMean(sum int, count int) int {
+    n, d := 2*sum+count, 2*count
+    q := n / d
+    if n%d != 0 && n < 0 {
+        q--
+    }
+    return q
+}
+
+// formatTenths prints a value in tenths with one decimal digit, never
+// producing "-0.0"
+func formatTenths(t int) string {
+    if t < 0 {
+        return fmt.Sprintf("-%d.%d", -t/10, -t%10)
+    }
+    return fmt.Sprintf("%d.%d", t/10, t%10)
+}
+
 func bytesToInt(byteArray []byte) int {
     var result int
     negative := false